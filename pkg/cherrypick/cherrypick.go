@@ -0,0 +1,89 @@
+package cherrypick
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/klog"
+
+	"github.com/submariner-io/submariner-bot/pkg/ghclient"
+	"github.com/submariner-io/submariner-bot/pkg/git"
+)
+
+// LabelPrefix is used to label PRs which should be cherry-picked to the branch named after the prefix once merged
+const LabelPrefix = "cherry-pick/"
+
+// PR is what is needed from a merged PR to cherry-pick it
+type PR struct {
+	Num      int
+	Title    string
+	MergeSHA string
+}
+
+// Targets returns the branches a PR should be cherry-picked to, according to its labels
+func Targets(labels []string) []string {
+	targets := []string{}
+	for _, label := range labels {
+		if strings.HasPrefix(label, LabelPrefix) {
+			targets = append(targets, strings.TrimPrefix(label, LabelPrefix))
+		}
+	}
+	return targets
+}
+
+// Run cherry-picks the commits of a merged PR onto a new branch off target, pushes it and opens a backport PR
+func Run(gh ghclient.GH, gitRepo *git.Git, pr *PR, target string) error {
+	branches, err := gitRepo.GetBranches()
+	if err != nil {
+		klog.Errorf("Error getting branches for origin repo: %s", err)
+		return err
+	}
+
+	targetHash := branches[target]
+	if targetHash == nil {
+		gh.CommentOnPR(pr.Num, "I can't cherry-pick to %s, the branch doesn't exist", target)
+		return nil
+	}
+
+	messages, err := gh.ListPRCommitMessages(pr.Num)
+	if err != nil {
+		klog.Errorf("listing commits of PR #%d: %s", pr.Num, err)
+		return err
+	}
+
+	commits, err := gitRepo.MergedCommits(pr.MergeSHA, messages)
+	if err != nil {
+		klog.Errorf("finding commits merged by PR #%d: %s", pr.Num, err)
+		return err
+	}
+
+	branch := fmt.Sprintf("z_cherry-pick%d/%s", pr.Num, target)
+	klog.Infof("Cherry-picking %v from PR #%d to %s as %s", commits, pr.Num, target, branch)
+
+	err = gitRepo.CherryPick(branch, targetHash.String(), commits)
+	if conflict, ok := err.(*git.ConflictError); ok {
+		gh.CommentOnPR(pr.Num, "I couldn't cherry-pick this PR to %s, commit %s conflicts on:\n- %s\n\n"+
+			"Please open the backport PR manually.", target, conflict.Commit, strings.Join(conflict.Files, "\n- "))
+		return nil
+	} else if err != nil {
+		klog.Errorf("cherry-picking PR #%d to %s: %s", pr.Num, target, err)
+		return err
+	}
+
+	if err = gitRepo.Push(branch); err != nil {
+		klog.Errorf("Error pushing origin with the new branch: %s", err)
+		gh.CommentOnPR(pr.Num, "I had an issue pushing the cherry-pick branch: %s", err)
+		return err
+	}
+
+	backport, err := gh.CreatePR(fmt.Sprintf("[%s] %s", target, pr.Title), branch, target,
+		fmt.Sprintf("Automated cherry-pick of #%d to %s.", pr.Num, target))
+	if err != nil {
+		klog.Errorf("creating backport PR of #%d to %s: %s", pr.Num, target, err)
+		gh.CommentOnPR(pr.Num, "I pushed %s but had an issue opening the backport PR: %s", branch, err)
+		return err
+	}
+
+	gh.CommentOnPR(pr.Num, "Opened backport PR to %s: %s", target, backport.GetHTMLURL())
+	return nil
+}
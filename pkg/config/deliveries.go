@@ -0,0 +1,40 @@
+package config
+
+import (
+	"os"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	DeliveriesWindowEnvVar   = "DELIVERIES_WINDOW"
+	DeliveriesCapacityEnvVar = "DELIVERIES_CAPACITY"
+
+	defaultDeliveriesWindow   = 24 * time.Hour
+	defaultDeliveriesCapacity = 10000
+)
+
+// GetDeliveriesWindow returns for how long handled deliveries are remembered to detect redeliveries
+func GetDeliveriesWindow() time.Duration {
+	return getPositiveDurationFromEnv(DeliveriesWindowEnvVar, defaultDeliveriesWindow)
+}
+
+// GetDeliveriesCapacity returns how many handled deliveries are remembered at most to detect redeliveries
+func GetDeliveriesCapacity() int {
+	return getPositiveIntFromEnv(DeliveriesCapacityEnvVar, defaultDeliveriesCapacity)
+}
+
+func getPositiveDurationFromEnv(envVar string, defaultValue time.Duration) time.Duration {
+	str := os.Getenv(envVar)
+	if str == "" {
+		return defaultValue
+	}
+
+	val, err := time.ParseDuration(str)
+	if err != nil || val <= 0 {
+		klog.Warningf("Ignoring invalid value %q for %s, using %s", str, envVar, defaultValue)
+		return defaultValue
+	}
+	return val
+}
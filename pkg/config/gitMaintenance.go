@@ -0,0 +1,21 @@
+package config
+
+import "time"
+
+const (
+	GitMaintenanceIntervalEnvVar = "GIT_MAINTENANCE_INTERVAL"
+	GitDiskBudgetEnvVar          = "GIT_DISK_BUDGET_MB"
+
+	defaultGitMaintenanceInterval = 6 * time.Hour
+)
+
+// GetGitMaintenanceInterval returns how often the cloned repositories are cleaned up
+func GetGitMaintenanceInterval() time.Duration {
+	return getPositiveDurationFromEnv(GitMaintenanceIntervalEnvVar, defaultGitMaintenanceInterval)
+}
+
+// GetGitDiskBudget returns how many bytes the cloned repositories can take before the least recently used ones
+// are removed, 0 means there's no limit
+func GetGitDiskBudget() int64 {
+	return int64(getPositiveIntFromEnv(GitDiskBudgetEnvVar, 0)) * 1024 * 1024
+}
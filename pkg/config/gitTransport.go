@@ -0,0 +1,28 @@
+package config
+
+import (
+	"os"
+
+	"k8s.io/klog"
+)
+
+const (
+	GitTransportEnvVar = "GIT_TRANSPORT"
+
+	GitTransportSSH   = "ssh"
+	GitTransportHTTPS = "https"
+)
+
+// GetGitTransport returns how git remotes are accessed, either over SSH with the SSH key or over https with
+// the GitHub token
+func GetGitTransport() string {
+	switch transport := os.Getenv(GitTransportEnvVar); transport {
+	case "", GitTransportSSH:
+		return GitTransportSSH
+	case GitTransportHTTPS:
+		return GitTransportHTTPS
+	default:
+		klog.Warningf("Ignoring invalid value %q for %s, using %s", transport, GitTransportEnvVar, GitTransportSSH)
+		return GitTransportSSH
+	}
+}
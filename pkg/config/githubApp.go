@@ -0,0 +1,89 @@
+package config
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+const (
+	GithubAppIDEnvVar = "GITHUB_APP_ID"
+	GithubAppPKEnvVar = "GITHUB_APP_PK"
+
+	githubAppIDEntry = "githubAppID"
+	githubAppPKEntry = "githubAppPK"
+)
+
+// GithubApp holds the credentials of the GitHub App the bot authenticates as
+type GithubApp struct {
+	ID         int64
+	PrivateKey *rsa.PrivateKey
+}
+
+// GetGithubApp returns the GitHub App credentials, from the GITHUB_APP_ID and GITHUB_APP_PK env vars or from
+// the k8s secret, or nil if the bot authenticates with a personal token
+func GetGithubApp() (*GithubApp, error) {
+	if id := os.Getenv(GithubAppIDEnvVar); id != "" {
+		pk, err := os.ReadFile(os.Getenv(GithubAppPKEnvVar))
+		if err != nil {
+			return nil, err
+		}
+		return parseGithubApp(id, pk)
+	}
+
+	// A token in the env takes precedence over the secret
+	if getGithubTokenFromEnv() != "" {
+		return nil, nil
+	}
+
+	secret, err := getK8sSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := secret.Data[githubAppIDEntry]
+	if !ok {
+		return nil, nil
+	}
+
+	pk, ok := secret.Data[githubAppPKEntry]
+	if !ok {
+		err := fmt.Errorf("secret %s does not contain file %s", secretName, githubAppPKEntry)
+		klog.Error(err.Error())
+		return nil, err
+	}
+	return parseGithubApp(string(id), pk)
+}
+
+func parseGithubApp(id string, pk []byte) (*GithubApp, error) {
+	appID, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub App ID %q: %s", id, err)
+	}
+
+	block, _ := pem.Decode(pk)
+	if block == nil {
+		return nil, fmt.Errorf("the GitHub App private key isn't PEM encoded")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if pkcs8Err != nil {
+			return nil, fmt.Errorf("error parsing the GitHub App private key: %s", err)
+		}
+
+		var ok bool
+		if key, ok = parsed.(*rsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("the GitHub App private key isn't an RSA key")
+		}
+	}
+
+	return &GithubApp{ID: appID, PrivateKey: key}, nil
+}
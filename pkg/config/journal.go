@@ -0,0 +1,17 @@
+package config
+
+import "os"
+
+const (
+	JournalPathEnvVar  = "JOURNAL_PATH"
+	defaultJournalPath = "/tmp/submariner-bot/journal"
+)
+
+// GetJournalPath returns where accepted webhook deliveries are persisted, it should be on a volume
+// which survives pod restarts
+func GetJournalPath() string {
+	if path := os.Getenv(JournalPathEnvVar); path != "" {
+		return path
+	}
+	return defaultJournalPath
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+
+	"k8s.io/klog"
+)
+
+const (
+	SSHKnownHostsEnvVar = "SSH_KNOWN_HOSTS"
+
+	sshKnownHostsEntry = "known_hosts"
+
+	// githubKnownHosts are the SSH host keys published by GitHub
+	githubKnownHosts = `github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl
+github.com ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBEmKSENjQEezOmxkZMy7opKgwFB9nkt5YRrYMjNuG5N87uRgg6CLrbo5wAdT/y6v0mKV0U2w0WZ2YB/++Tpockg=
+github.com ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABgQCj7ndNxQowgcQnjshcLrqPEiiphnt+VTTvDP6mHBL9j1aNUkY4Ue1gvwnGLVlOhGeYrnZaMgRK6+PKCUXaDbC7qtbW8gIkhL7aGCsOr/C56SJMy/BCZfxd1nWzAOxSDPgVsmerOBYfNqltV9/hWCqBywINIR+5dIg6JTJ72pcEpEjcYgXkE2YEFXV1JHnsKgbLWNlhScqb2UmyRkQyytRLtL+38TGxkxCflmO+5Z8CSSNY7GidjMIZ7Q4zMjA2n1nGrlTDkzwDCsw+wqFPGQA179cnfGWOWRVruj16z6XyvxvjJwbz0wQZ75XK5tKSb7FNyeIEs4TT4jk+S4dhPeAUC5y+bDYirYgM4GC7uEnztnZyaVWQ7B381AK4Qdrwt51ZqExKbQpTUNn+EjqoTwvqNj4kqx5QUCI0ThS/YkOxJCXmPUWZbhjpCg56i+2aB6CmK2JGhn57K5mj0MNdBXA4/WnwH6XoPWJzK5Nyu2zB3nAZp+S5hpQs+p1vN1/wsjk=
+`
+)
+
+// GetSSHKnownHosts returns the known_hosts used to verify the SSH host keys, from the file in SSH_KNOWN_HOSTS,
+// the k8s secret, or the keys published by GitHub when neither is provided
+func GetSSHKnownHosts() ([]byte, error) {
+	if path := os.Getenv(SSHKnownHostsEnvVar); path != "" {
+		klog.Infof("SSH known hosts obtained from %s", path)
+		return os.ReadFile(path)
+	}
+
+	secret, err := getK8sSecret()
+	if err != nil {
+		klog.Infof("Using the GitHub SSH host keys, the k8s secret isn't available: %s", err)
+		return []byte(githubKnownHosts), nil
+	}
+
+	if val, ok := secret.Data[sshKnownHostsEntry]; ok {
+		klog.Info("SSH known hosts obtained from k8s secret")
+		return val, nil
+	}
+
+	return []byte(githubKnownHosts), nil
+}
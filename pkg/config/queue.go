@@ -0,0 +1,40 @@
+package config
+
+import (
+	"os"
+	"strconv"
+
+	"k8s.io/klog"
+)
+
+const (
+	QueueWorkersEnvVar  = "QUEUE_WORKERS"
+	QueueCapacityEnvVar = "QUEUE_CAPACITY"
+
+	defaultQueueWorkers  = 4
+	defaultQueueCapacity = 256
+)
+
+// GetQueueWorkers returns how many repositories can have events handled concurrently
+func GetQueueWorkers() int {
+	return getPositiveIntFromEnv(QueueWorkersEnvVar, defaultQueueWorkers)
+}
+
+// GetQueueCapacity returns how many events can be waiting to be handled before new ones are rejected
+func GetQueueCapacity() int {
+	return getPositiveIntFromEnv(QueueCapacityEnvVar, defaultQueueCapacity)
+}
+
+func getPositiveIntFromEnv(envVar string, defaultValue int) int {
+	str := os.Getenv(envVar)
+	if str == "" {
+		return defaultValue
+	}
+
+	val, err := strconv.Atoi(str)
+	if err != nil || val <= 0 {
+		klog.Warningf("Ignoring invalid value %q for %s, using %d", str, envVar, defaultValue)
+		return defaultValue
+	}
+	return val
+}
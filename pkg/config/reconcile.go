@@ -0,0 +1,43 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	RepositoriesEnvVar      = "REPOSITORIES"
+	BranchGCIntervalEnvVar  = "BRANCH_GC_INTERVAL"
+	BranchGCDryRunEnvVar    = "BRANCH_GC_DRY_RUN"
+	ReconcileIntervalEnvVar = "RECONCILE_INTERVAL"
+
+	defaultBranchGCInterval  = 24 * time.Hour
+	defaultReconcileInterval = 6 * time.Hour
+)
+
+// GetRepositories returns the full names of the repositories periodically checked for missed events
+func GetRepositories() []string {
+	repos := []string{}
+	for _, repo := range strings.Split(os.Getenv(RepositoriesEnvVar), ",") {
+		if repo = strings.TrimSpace(repo); repo != "" {
+			repos = append(repos, repo)
+		}
+	}
+	return repos
+}
+
+// GetBranchGCInterval returns how often the z_pr branches of closed PRs are looked for
+func GetBranchGCInterval() time.Duration {
+	return getPositiveDurationFromEnv(BranchGCIntervalEnvVar, defaultBranchGCInterval)
+}
+
+// IsBranchGCDryRun returns whether the z_pr branches of closed PRs are only reported instead of deleted
+func IsBranchGCDryRun() bool {
+	return os.Getenv(BranchGCDryRunEnvVar) == "true"
+}
+
+// GetReconcileInterval returns how often the open PRs are checked for missed events
+func GetReconcileInterval() time.Duration {
+	return getPositiveDurationFromEnv(ReconcileIntervalEnvVar, defaultReconcileInterval)
+}
@@ -2,39 +2,102 @@ package repoconfig
 
 import (
 	"fmt"
+	"sync"
 
+	"github.com/submariner-io/submariner-bot/pkg/ghclient"
 	"github.com/submariner-io/submariner-bot/pkg/git"
 	"gopkg.in/yaml.v2"
 	"k8s.io/klog"
 )
 
 const (
-	defaultApprovals = 2
-	defaultLabel     = "ready-to-test"
-	filename         = ".submarinerbot.yaml"
+	defaultApprovals          = 2
+	defaultLabel              = "ready-to-test"
+	defaultReleaseLabelPrefix = "released-in/"
+	defaultVersionRetention   = 10
+	filename                  = ".submarinerbot.yaml"
+	cacheCapacity             = 256
 )
 
 type BotConfig struct {
 	LabelApproved *struct {
 		Approvals *int
 		Label     *string
+		// ResetOnPush removes the label when new commits are pushed, and only counts approvals of the latest commit
+		ResetOnPush *bool `yaml:"reset-on-push"`
+		// TrustedAuthors are exempt from ResetOnPush
+		TrustedAuthors []string `yaml:"trusted-authors"`
 	} `yaml:"label-approved"`
+	CommentReleased *struct {
+		Label       *bool
+		LabelPrefix *string `yaml:"label-prefix"`
+	} `yaml:"comment-released"`
+	BranchVersioning *struct {
+		Retention *int
+	} `yaml:"branch-versioning"`
+	Commands *struct {
+		Enabled []string
+	} `yaml:"commands"`
 }
 
+var (
+	cacheLock sync.Mutex
+	// cache holds the parsed configs per commit, the oldest ones are evicted first
+	cache      = make(map[string]*BotConfig)
+	cachedSHAs = []string{}
+)
+
+// Read returns the config as it is in a commit, the returned config is shared and mustn't be modified
 func Read(gitRepo *git.Git, sha string) (*BotConfig, error) {
-	err := gitRepo.CheckoutHash(sha)
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	if config, ok := cache[sha]; ok {
+		return config, nil
+	}
+
+	config, err := read(gitRepo, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cachedSHAs) >= cacheCapacity {
+		delete(cache, cachedSHAs[0])
+		cachedSHAs = cachedSHAs[1:]
+	}
+	cache[sha] = config
+	cachedSHAs = append(cachedSHAs, sha)
+
+	return config, nil
+}
+
+// ReadDefaultBranch returns the config on the default branch through the API, without needing a clone. A repository
+// without config gets an empty one.
+func ReadDefaultBranch(gh ghclient.GH) (*BotConfig, error) {
+	buf, err := gh.GetFile(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	buf, err := gitRepo.ReadFile(filename)
+	if buf == nil {
+		return &BotConfig{}, nil
+	}
+	return parse(buf)
+}
+
+func read(gitRepo *git.Git, sha string) (*BotConfig, error) {
+	buf, err := gitRepo.ReadFileAt(sha, filename)
 	if err != nil {
 		return nil, err
 	}
 
 	klog.Infof("read the following config for %s: %s", git.Origin, string(buf))
+	return parse(buf)
+}
+
+func parse(buf []byte) (*BotConfig, error) {
 	config := &BotConfig{}
-	err = yaml.Unmarshal(buf, config)
+	err := yaml.Unmarshal(buf, config)
 	if err != nil {
 		return nil, fmt.Errorf("in file %q: %v", filename, err)
 	}
@@ -49,7 +112,65 @@ func Read(gitRepo *git.Git, sha string) (*BotConfig, error) {
 			v := defaultLabel
 			config.LabelApproved.Label = &v
 		}
+
+		if config.LabelApproved.ResetOnPush == nil {
+			v := false
+			config.LabelApproved.ResetOnPush = &v
+		}
+	}
+
+	if config.CommentReleased != nil {
+		if config.CommentReleased.Label == nil {
+			v := false
+			config.CommentReleased.Label = &v
+		}
+
+		if config.CommentReleased.LabelPrefix == nil {
+			v := defaultReleaseLabelPrefix
+			config.CommentReleased.LabelPrefix = &v
+		}
+	}
+
+	if config.BranchVersioning != nil {
+		if config.BranchVersioning.Retention == nil {
+			v := defaultVersionRetention
+			config.BranchVersioning.Retention = &v
+		}
+
+		// The current version is always kept
+		if *config.BranchVersioning.Retention < 1 {
+			return nil, fmt.Errorf("in file %q: branch-versioning retention must be at least 1, got %d", filename,
+				*config.BranchVersioning.Retention)
+		}
 	}
 
 	return config, nil
 }
+
+// CommandEnabled returns whether a PR comment command is enabled for the repository
+func (c *BotConfig) CommandEnabled(name string) bool {
+	if c.Commands == nil {
+		return false
+	}
+
+	for _, enabled := range c.Commands.Enabled {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ResetsApprovalOnPush returns whether pushing new commits to a PR from the given author requires new approvals
+func (c *BotConfig) ResetsApprovalOnPush(author string) bool {
+	if c.LabelApproved == nil || !*c.LabelApproved.ResetOnPush {
+		return false
+	}
+
+	for _, trusted := range c.LabelApproved.TrustedAuthors {
+		if trusted == author {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package deliveries
+
+import (
+	"sync"
+	"time"
+)
+
+// Store remembers the GUIDs of deliveries handled successfully, for a limited time and up to a limited number
+// of them, so redeliveries of the same event can be detected
+type Store struct {
+	lock     sync.Mutex
+	window   time.Duration
+	capacity int
+	handled  map[string]time.Time
+	// order keeps the GUIDs in the order they were added, the oldest first
+	order []string
+}
+
+func NewStore(window time.Duration, capacity int) *Store {
+	return &Store{
+		window:   window,
+		capacity: capacity,
+		handled:  make(map[string]time.Time),
+	}
+}
+
+// Handled returns whether the delivery was handled successfully within the window
+func (s *Store) Handled(id string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.expire()
+	_, ok := s.handled[id]
+	return ok
+}
+
+// Add records a delivery as handled successfully
+func (s *Store) Add(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.handled[id]; !ok {
+		s.order = append(s.order, id)
+	}
+	s.handled[id] = time.Now()
+
+	s.expire()
+	for len(s.order) > s.capacity {
+		delete(s.handled, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+func (s *Store) expire() {
+	deadline := time.Now().Add(-s.window)
+	for len(s.order) > 0 {
+		id := s.order[0]
+		if handledAt, ok := s.handled[id]; ok && handledAt.After(deadline) {
+			return
+		}
+		delete(s.handled, id)
+		s.order = s.order[1:]
+	}
+}
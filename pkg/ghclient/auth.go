@@ -0,0 +1,175 @@
+package ghclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"golang.org/x/oauth2"
+	"k8s.io/klog"
+
+	"github.com/submariner-io/submariner-bot/pkg/config"
+)
+
+// installationTokenMargin is how long before their expiry installation tokens are refreshed
+const installationTokenMargin = 5 * time.Minute
+
+var (
+	installationsLock sync.Mutex
+	// installations maps the repositories to the ID of the app installation on them
+	installations = make(map[string]int64)
+	// installationTokens holds a reusable token source per installation
+	installationTokens = make(map[int64]oauth2.TokenSource)
+)
+
+// TokenSource returns the source of the tokens used to access a repository, which is the configured personal token,
+// or the token of the GitHub App installation on the repository when the bot runs as a GitHub App
+func TokenSource(owner, repo string) (oauth2.TokenSource, error) {
+	source, _, err := tokenSource(owner, repo)
+	return source, err
+}
+
+// tokenSource is TokenSource, also returning the ID of the GitHub App installation the tokens are for, or 0 for the
+// personal token
+func tokenSource(owner, repo string) (oauth2.TokenSource, int64, error) {
+	app, err := config.GetGithubApp()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if app == nil {
+		token, err := config.GetGithubToken()
+		if err != nil {
+			return nil, 0, err
+		}
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}), 0, nil
+	}
+
+	return installationTokenSource(app, owner, repo)
+}
+
+func installationTokenSource(app *config.GithubApp, owner, repo string) (oauth2.TokenSource, int64, error) {
+	installationsLock.Lock()
+	defer installationsLock.Unlock()
+
+	appClient := newAppClient(app)
+
+	fullName := owner + "/" + repo
+	id, ok := installations[fullName]
+	if !ok {
+		installation, _, err := appClient.Apps.FindRepositoryInstallation(context.Background(), owner, repo)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error finding the installation of GitHub App %d on %s: %s", app.ID, fullName, err)
+		}
+		id = installation.GetID()
+		installations[fullName] = id
+	}
+
+	if source, ok := installationTokens[id]; ok {
+		return source, id, nil
+	}
+
+	source := oauth2.ReuseTokenSource(nil, &installationMinter{client: appClient, id: id})
+	installationTokens[id] = source
+	return source, id, nil
+}
+
+// appLogin returns the login of the GitHub App's bot user, which posts the comments of the installations
+func appLogin() (string, error) {
+	app, err := config.GetGithubApp()
+	if err != nil {
+		return "", err
+	}
+
+	// The slug isn't in the App of this go-github version
+	appClient := newAppClient(app)
+	req, err := appClient.NewRequest(http.MethodGet, "app", nil)
+	if err != nil {
+		return "", err
+	}
+
+	info := &struct {
+		Slug string `json:"slug"`
+	}{}
+	if _, err := appClient.Do(context.Background(), req, info); err != nil {
+		return "", fmt.Errorf("error getting GitHub App %d: %s", app.ID, err)
+	}
+	return info.Slug + "[bot]", nil
+}
+
+// newAppClient returns a client authenticated as the GitHub App itself
+func newAppClient(app *config.GithubApp) *github.Client {
+	return github.NewClient(&http.Client{Transport: &appTransport{app: app, base: http.DefaultTransport}})
+}
+
+// installationMinter mints tokens for an app installation
+type installationMinter struct {
+	client *github.Client
+	id     int64
+}
+
+func (it *installationMinter) Token() (*oauth2.Token, error) {
+	token, _, err := it.client.Apps.CreateInstallationToken(context.Background(), it.id, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating a token for installation %d: %s", it.id, err)
+	}
+
+	klog.Infof("Created a token for installation %d, expiring at %s", it.id, token.GetExpiresAt())
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		Expiry:      token.GetExpiresAt().Add(-installationTokenMargin),
+	}, nil
+}
+
+// appTransport authenticates requests as the GitHub App itself, which is only needed to get installation tokens
+type appTransport struct {
+	app  *config.GithubApp
+	base http.RoundTripper
+}
+
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := appJWT(t.app)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	return t.base.RoundTrip(req)
+}
+
+// appJWT signs the JWT identifying the GitHub App, backdated to allow for clock drift
+func appJWT(app *config.GithubApp) (string, error) {
+	now := time.Now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": app.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, app.PrivateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
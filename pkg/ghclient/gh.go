@@ -3,36 +3,52 @@ package ghclient
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/google/go-github/v28/github"
 	"golang.org/x/oauth2"
 	"k8s.io/klog"
-
-	"github.com/submariner-io/submariner-bot/pkg/config"
 )
 
 type GH interface {
 	AddLabel(issueOrPRNum int, label string) error
+	RemoveLabel(issueOrPRNum int, label string) error
 	CommentOnPR(prNum int, comment string, args ...interface{})
+	// StickyComment creates or edits the single comment of the given kind on the PR
+	StickyComment(prNum int, kind, comment string, args ...interface{})
+	// UpdateStickyComment edits the comment of the given kind on the PR, if there is one
+	UpdateStickyComment(prNum int, kind, comment string, args ...interface{})
+	GetPRStack(prNum int, prBranches map[int][]string) (*StackNode, error)
+	GetPR(prNum int) (*github.PullRequest, error)
+	// GetFile returns the content of a file on the default branch, or nil if there's no such file
+	GetFile(path string) ([]byte, error)
+	CreatePR(title, head, base, body string) (*github.PullRequest, error)
+	ListPRCommitMessages(prNum int) ([]string, error)
+	GetPermissionLevel(user string) (string, error)
+	RerunFailedWorkflows(sha string) (int, error)
 	ListReviews(prNum int) ([]*github.PullRequestReview, error)
-	UpdateDependingPRs(prNum int, baseRef string, branchesToDelete []string) error
+	ListMergedPRsWithCommit(sha string) ([]*github.PullRequest, error)
+	ListDependentPRs(branch string) ([]*github.PullRequest, error)
+	ListOpenPRs() ([]*github.PullRequest, error)
+	UpdateDependingPRs(prNum int, baseRef string, branchesToDelete []string) (map[int]string, error)
 }
 
 func New(owner, repo string) (GH, error) {
 	ctx := context.Background()
-	token, err := config.GetGithubToken()
+	ts, installation, err := tokenSource(owner, repo)
 	if err != nil {
 		return nil, err
 	}
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
+	// The oauth2 transport sits on top of the rate limit one
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transportFor(installation)})
 	tc := oauth2.NewClient(ctx, ts)
 
 	gh := ghClient{
 		client: github.NewClient(tc),
 		owner:  owner,
 		repo:   repo,
+		asApp:  installation != 0,
 	}
 	return &gh, nil
 }
@@ -41,6 +57,8 @@ type ghClient struct {
 	client *github.Client
 	owner  string
 	repo   string
+	// asApp is set when the client authenticates as a GitHub App installation instead of a user
+	asApp bool
 }
 
 func (gh ghClient) AddLabel(issueOrPRNum int, label string) error {
@@ -53,9 +71,26 @@ func (gh ghClient) AddLabel(issueOrPRNum int, label string) error {
 	return err
 }
 
+func (gh ghClient) RemoveLabel(issueOrPRNum int, label string) error {
+	resp, err := gh.client.Issues.RemoveLabelForIssue(
+		context.Background(),
+		gh.owner,
+		gh.repo,
+		issueOrPRNum,
+		label)
+	// The label wasn't there, which is what we wanted anyway
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
 func (gh ghClient) CommentOnPR(prNum int, comment string, args ...interface{}) {
 	// In GitHub PRs are a sort of issue, so some operations need to be done on the Issues API
-	comment = "🤖 " + fmt.Sprintf(comment, args...)
+	gh.createComment(prNum, "🤖 "+fmt.Sprintf(comment, args...))
+}
+
+func (gh ghClient) createComment(prNum int, comment string) {
 	prComment := github.IssueComment{Body: &comment}
 	_, resp, err := gh.client.Issues.CreateComment(
 		context.Background(),
@@ -69,22 +104,150 @@ func (gh ghClient) CommentOnPR(prNum int, comment string, args ...interface{}) {
 	}
 }
 
+func (gh ghClient) GetPR(prNum int) (*github.PullRequest, error) {
+	pr, _, err := gh.client.PullRequests.Get(context.Background(), gh.owner, gh.repo, prNum)
+	return pr, err
+}
+
+func (gh ghClient) GetFile(path string) ([]byte, error) {
+	file, _, resp, err := gh.client.Repositories.GetContents(context.Background(), gh.owner, gh.repo, path, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s isn't a file", path)
+	}
+
+	content, err := file.GetContent()
+	return []byte(content), err
+}
+
+func (gh ghClient) CreatePR(title, head, base, body string) (*github.PullRequest, error) {
+	pr, _, err := gh.client.PullRequests.Create(context.Background(), gh.owner, gh.repo, &github.NewPullRequest{
+		Title: &title,
+		Head:  &head,
+		Base:  &base,
+		Body:  &body,
+	})
+	return pr, err
+}
+
+// ListPRCommitMessages: gets the messages of the commits in a PR, oldest first
+func (gh ghClient) ListPRCommitMessages(prNum int) ([]string, error) {
+	commits, err := listAll(fmt.Sprintf("commits of pr %d", prNum),
+		func(opts *github.ListOptions) ([]*github.RepositoryCommit, *github.Response, error) {
+			return gh.client.PullRequests.ListCommits(context.Background(), gh.owner, gh.repo, prNum, opts)
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []string{}
+	for _, commit := range commits {
+		messages = append(messages, commit.GetCommit().GetMessage())
+	}
+	return messages, nil
+}
+
+// GetPermissionLevel: gets the permission of a user on the repository, one of admin, write, read or none
+func (gh ghClient) GetPermissionLevel(user string) (string, error) {
+	level, _, err := gh.client.Repositories.GetPermissionLevel(context.Background(), gh.owner, gh.repo, user)
+	if err != nil {
+		return "", err
+	}
+	return level.GetPermission(), nil
+}
+
+type workflowRun struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Conclusion string `json:"conclusion"`
+}
+
+type workflowRuns struct {
+	WorkflowRuns []workflowRun `json:"workflow_runs"`
+}
+
+// RerunFailedWorkflows: re-runs the failed jobs of the workflow runs for a commit, returning how many runs were restarted
+func (gh ghClient) RerunFailedWorkflows(sha string) (int, error) {
+	ctx := context.Background()
+	// The actions API isn't supported by this version of go-github
+	runs, err := listAll(fmt.Sprintf("workflow runs for %s", sha),
+		func(opts *github.ListOptions) ([]workflowRun, *github.Response, error) {
+			req, err := gh.client.NewRequest("GET", fmt.Sprintf("repos/%s/%s/actions/runs?head_sha=%s&per_page=%d&page=%d",
+				gh.owner, gh.repo, sha, opts.PerPage, opts.Page), nil)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			runs := &workflowRuns{}
+			resp, err := gh.client.Do(ctx, req, runs)
+			return runs.WorkflowRuns, resp, err
+		})
+	if err != nil {
+		return 0, err
+	}
+
+	rerun := 0
+	for _, run := range runs {
+		if run.Conclusion != "failure" && run.Conclusion != "cancelled" && run.Conclusion != "timed_out" {
+			continue
+		}
+
+		req, err := gh.client.NewRequest("POST",
+			fmt.Sprintf("repos/%s/%s/actions/runs/%d/rerun-failed-jobs", gh.owner, gh.repo, run.ID), nil)
+		if err != nil {
+			return rerun, err
+		}
+		if _, err := gh.client.Do(ctx, req, nil); err != nil {
+			klog.Errorf("re-running workflow %s (%d): %s", run.Name, run.ID, err)
+			return rerun, err
+		}
+		rerun++
+	}
+
+	return rerun, nil
+}
+
 func (gh ghClient) ListReviews(prNum int) ([]*github.PullRequestReview, error) {
-	reviews, _, err := gh.client.PullRequests.ListReviews(
-		context.Background(),
-		gh.owner,
-		gh.repo,
-		prNum,
-		&github.ListOptions{PerPage: 100})
+	return listAll(fmt.Sprintf("reviews of pr %d", prNum),
+		func(opts *github.ListOptions) ([]*github.PullRequestReview, *github.Response, error) {
+			return gh.client.PullRequests.ListReviews(context.Background(), gh.owner, gh.repo, prNum, opts)
+		})
+}
 
-	return reviews, err
+// ListMergedPRsWithCommit: gets the merged pull requests which include a specific commit
+func (gh ghClient) ListMergedPRsWithCommit(sha string) ([]*github.PullRequest, error) {
+	list, err := listAll(fmt.Sprintf("prs with commit %s", sha),
+		func(opts *github.ListOptions) ([]*github.PullRequest, *github.Response, error) {
+			return gh.client.PullRequests.ListPullRequestsWithCommit(context.Background(), gh.owner, gh.repo, sha,
+				&github.PullRequestListOptions{ListOptions: *opts})
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	merged := []*github.PullRequest{}
+	for _, pr := range list {
+		if pr.MergedAt != nil {
+			merged = append(merged, pr)
+		}
+	}
+	return merged, nil
 }
 
 // fetchPRsWithBase: gets a list of pull requests which have an specific branch as base
 func (gh ghClient) fetchPRsWithBase(baseBranch string) ([]*github.PullRequest, error) {
-	list, _, err := gh.client.PullRequests.List(context.Background(), gh.owner, gh.repo, &github.PullRequestListOptions{
-		Base: baseBranch,
-	})
+	list, err := listAll(fmt.Sprintf("prs based on %s", baseBranch),
+		func(opts *github.ListOptions) ([]*github.PullRequest, *github.Response, error) {
+			return gh.client.PullRequests.List(context.Background(), gh.owner, gh.repo, &github.PullRequestListOptions{
+				Base:        baseBranch,
+				ListOptions: *opts,
+			})
+		})
 	if err != nil {
 		klog.Errorf("An error happened while trying to find PRs dependent on branch: %s on repo %s/%s", baseBranch,
 			gh.owner, gh.repo)
@@ -93,34 +256,52 @@ func (gh ghClient) fetchPRsWithBase(baseBranch string) ([]*github.PullRequest, e
 	return list, err
 }
 
-func (gh ghClient) UpdateDependingPRs(prNum int, baseRef string, branchesToDelete []string) error {
+// ListDependentPRs: gets the open pull requests based on a branch
+func (gh ghClient) ListDependentPRs(branch string) ([]*github.PullRequest, error) {
+	return gh.fetchPRsWithBase(branch)
+}
+
+// ListOpenPRs: gets all the open pull requests of the repository
+func (gh ghClient) ListOpenPRs() ([]*github.PullRequest, error) {
+	return listAll(fmt.Sprintf("open prs of %s/%s", gh.owner, gh.repo),
+		func(opts *github.ListOptions) ([]*github.PullRequest, *github.Response, error) {
+			return gh.client.PullRequests.List(context.Background(), gh.owner, gh.repo,
+				&github.PullRequestListOptions{State: "open", ListOptions: *opts})
+		})
+}
+
+// UpdateDependingPRs: retargets the PRs based on the branches being deleted to baseRef, returning the retargeted
+// PRs along with the branch each one was based on. Commenting on the retargeted PRs is up to the caller.
+func (gh ghClient) UpdateDependingPRs(prNum int, baseRef string, branchesToDelete []string) (map[int]string, error) {
+	retargeted := make(map[int]string)
+	updates := []string{}
+	defer func() {
+		if len(updates) > 0 {
+			gh.StickyComment(prNum, dependentsCommentKind, "Updating dependent PRs:\n%s", strings.Join(updates, "\n"))
+		}
+	}()
+
 	for _, branchName := range branchesToDelete {
 		prs, err := gh.fetchPRsWithBase(branchName)
 		if err != nil {
 			klog.Errorf("Error fetching dependent PRs for %s: %s", branchName, err)
-			gh.CommentOnPR(prNum, "Error fetching dependent PRs for %s: %s", branchName, err)
-			return err
+			updates = append(updates, fmt.Sprintf("- Error fetching dependent PRs for %s: %s", branchName, err))
+			return retargeted, err
 		}
 
 		for _, dependentPr := range prs {
-			gh.CommentOnPR(prNum, "Updating dependent PRs: %s", *dependentPr.HTMLURL)
-
-			// The PR payloadPR has been merged to pr.PullRequest.Base.Ref, so that should be the new base
-			// of the dependent PRs
 			dependentPr.Base.Ref = &baseRef
 			_, _, err := gh.client.PullRequests.Edit(context.Background(), gh.owner, gh.repo,
 				*dependentPr.Number, dependentPr)
 			if err != nil {
 				klog.Errorf("updating dependent PR: %s : %s", *dependentPr.HTMLURL, err)
-				gh.CommentOnPR(prNum, "Error updating dependent PRs: %s : %s", *dependentPr.HTMLURL, err)
-				return err
+				updates = append(updates, fmt.Sprintf("- Error updating %s : %s", *dependentPr.HTMLURL, err))
+				return retargeted, err
 			}
-
-			gh.CommentOnPR(*dependentPr.Number,
-				"The base of this PR has been updated to %s\nPlease rebase this branch and remove %s related commits",
-				baseRef, *dependentPr.HTMLURL)
+			retargeted[*dependentPr.Number] = branchName
+			updates = append(updates, fmt.Sprintf("- %s now based on %s", *dependentPr.HTMLURL, baseRef))
 		}
 	}
 
-	return nil
+	return retargeted, nil
 }
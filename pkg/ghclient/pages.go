@@ -0,0 +1,35 @@
+package ghclient
+
+import (
+	"github.com/google/go-github/v28/github"
+	"k8s.io/klog"
+)
+
+const (
+	perPage = 100
+	// maxPages bounds how many pages a list operation fetches, in case something goes really wrong
+	maxPages = 50
+)
+
+// listAll calls list for every page, following the pages linked from each response
+func listAll[T any](what string, list func(opts *github.ListOptions) ([]T, *github.Response, error)) ([]T, error) {
+	all := []T{}
+	opts := &github.ListOptions{PerPage: perPage}
+	for page := 1; ; page++ {
+		items, resp, err := list(opts)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+
+		if page >= maxPages {
+			klog.Warningf("Stopped listing %s after %d pages, only the first %d are used", what, maxPages, len(all))
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
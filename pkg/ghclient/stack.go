@@ -0,0 +1,81 @@
+package ghclient
+
+import (
+	"github.com/google/go-github/v28/github"
+	"k8s.io/klog"
+)
+
+// StackNode is a PR in a stack of PRs, along with the PRs based on its branches
+type StackNode struct {
+	PR         *github.PullRequest
+	Dependents []*StackNode
+}
+
+// GetPRStack returns the root of the stack a PR is part of. prBranches maps PR numbers to the branches created for
+// them, a PR based on one of those branches depends on that PR.
+func (gh ghClient) GetPRStack(prNum int, prBranches map[int][]string) (*StackNode, error) {
+	branchOwners := make(map[string]int)
+	for num, branches := range prBranches {
+		for _, branch := range branches {
+			branchOwners[branch] = num
+		}
+	}
+
+	root, err := gh.GetPR(prNum)
+	if err != nil {
+		return nil, err
+	}
+
+	visited := map[int]bool{prNum: true}
+	for {
+		parentNum, ok := branchOwners[root.GetBase().GetRef()]
+		if !ok || visited[parentNum] {
+			break
+		}
+		visited[parentNum] = true
+
+		parent, err := gh.GetPR(parentNum)
+		if err != nil {
+			return nil, err
+		}
+		root = parent
+	}
+
+	return gh.stackNode(root, prBranches, make(map[int]bool))
+}
+
+func (gh ghClient) stackNode(pr *github.PullRequest, prBranches map[int][]string, visited map[int]bool) (*StackNode, error) {
+	node := &StackNode{PR: pr}
+	visited[pr.GetNumber()] = true
+
+	for _, branch := range prBranches[pr.GetNumber()] {
+		dependents, err := gh.fetchPRsWithBase(branch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, dependent := range dependents {
+			if visited[dependent.GetNumber()] {
+				klog.Warningf("PR #%d is already part of the stack of #%d", dependent.GetNumber(), pr.GetNumber())
+				continue
+			}
+
+			child, err := gh.stackNode(dependent, prBranches, visited)
+			if err != nil {
+				return nil, err
+			}
+			node.Dependents = append(node.Dependents, child)
+		}
+	}
+
+	return node, nil
+}
+
+// Members returns all the PRs in the stack, starting with the node's
+func (node *StackNode) Members() []*github.PullRequest {
+	members := []*github.PullRequest{node.PR}
+	for _, dependent := range node.Dependents {
+		members = append(members, dependent.Members()...)
+	}
+	return members
+}
@@ -0,0 +1,125 @@
+package ghclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v28/github"
+	"k8s.io/klog"
+)
+
+// dependentsCommentKind is the kind of the sticky comment listing the updates to the PRs depending on a PR
+const dependentsCommentKind = "dependents"
+
+var (
+	loginLock sync.Mutex
+	// botLogin is the login the bot comments as, once it's known
+	botLogin string
+)
+
+// stickyMarker is a hidden HTML comment identifying the kind of a sticky comment
+func stickyMarker(kind string) string {
+	return fmt.Sprintf("<!-- submariner-bot:%s -->", kind)
+}
+
+func (gh ghClient) StickyComment(prNum int, kind, comment string, args ...interface{}) {
+	gh.stickyComment(prNum, kind, true, comment, args...)
+}
+
+func (gh ghClient) UpdateStickyComment(prNum int, kind, comment string, args ...interface{}) {
+	gh.stickyComment(prNum, kind, false, comment, args...)
+}
+
+func (gh ghClient) stickyComment(prNum int, kind string, create bool, comment string, args ...interface{}) {
+	marker := stickyMarker(kind)
+	body := marker + "\n🤖 " + fmt.Sprintf(comment, args...)
+
+	existing, err := gh.findStickyComment(prNum, marker)
+	if err != nil {
+		// We don't propagate and just log the error
+		klog.Errorf("Error looking for the %s comment on pr %d: %s", kind, prNum, err)
+		return
+	}
+
+	if existing == nil {
+		if create {
+			gh.createComment(prNum, body)
+		}
+		return
+	}
+
+	if existing.GetBody() == body {
+		return
+	}
+
+	_, resp, err := gh.client.Issues.EditComment(context.Background(), gh.owner, gh.repo, existing.GetID(),
+		&github.IssueComment{Body: &body})
+	if err != nil {
+		klog.Errorf("Error editing the %s comment on pr %d: %s, response: %v", kind, prNum, err, resp)
+	}
+}
+
+func (gh ghClient) findStickyComment(prNum int, marker string) (*github.IssueComment, error) {
+	comments, err := listAll(fmt.Sprintf("comments of pr %d", prNum),
+		func(opts *github.ListOptions) ([]*github.IssueComment, *github.Response, error) {
+			return gh.client.Issues.ListComments(context.Background(), gh.owner, gh.repo, prNum,
+				&github.IssueListCommentsOptions{ListOptions: *opts})
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, comment := range comments {
+		if !strings.HasPrefix(comment.GetBody(), marker) {
+			continue
+		}
+
+		// Anybody can post the marker, but only our own comments can be edited
+		own, err := gh.isOwnComment(comment)
+		if err != nil {
+			return nil, err
+		}
+		if own {
+			return comment, nil
+		}
+	}
+	return nil, nil
+}
+
+// isOwnComment returns whether a comment was posted by the bot
+func (gh ghClient) isOwnComment(comment *github.IssueComment) (bool, error) {
+	login, err := gh.login()
+	if err != nil {
+		return false, err
+	}
+	return comment.GetUser().GetLogin() == login, nil
+}
+
+// login returns the login the bot comments as, the user the personal token belongs to, or the bot user of the
+// GitHub App, which installation tokens can't look up themselves. It's the same for every client.
+func (gh ghClient) login() (string, error) {
+	loginLock.Lock()
+	defer loginLock.Unlock()
+
+	if botLogin != "" {
+		return botLogin, nil
+	}
+
+	if gh.asApp {
+		login, err := appLogin()
+		if err != nil {
+			return "", err
+		}
+		botLogin = login
+		return botLogin, nil
+	}
+
+	user, _, err := gh.client.Users.Get(context.Background(), "")
+	if err != nil {
+		return "", fmt.Errorf("error getting the authenticated user: %s", err)
+	}
+	botLogin = user.GetLogin()
+	return botLogin, nil
+}
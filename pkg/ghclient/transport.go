@@ -0,0 +1,265 @@
+package ghclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/submariner-io/submariner-bot/pkg/metrics"
+)
+
+const (
+	// rateLimitReserve is how many requests are kept in reserve, once fewer remain requests wait for the reset
+	rateLimitReserve = 50
+	maxRetries       = 4
+	baseBackoff      = time.Second
+	maxBackoff       = 30 * time.Second
+)
+
+var (
+	transportsLock sync.Mutex
+	// transports holds a transport per quota: each installation of the GitHub App has its own quota, while the
+	// personal token has a single one for all the repositories, under installation 0
+	transports = make(map[int64]*rateLimitTransport)
+)
+
+// transportFor returns the transport shared by the clients using the quota of an installation, or of the personal
+// token for 0
+func transportFor(installation int64) *rateLimitTransport {
+	transportsLock.Lock()
+	defer transportsLock.Unlock()
+
+	t, ok := transports[installation]
+	if !ok {
+		quota := "token"
+		if installation != 0 {
+			quota = fmt.Sprintf("installation-%d", installation)
+		}
+		t = &rateLimitTransport{base: http.DefaultTransport, quota: quota}
+		transports[installation] = t
+	}
+	return t
+}
+
+// rateLimitTransport tracks an API quota from the response headers, delaying requests when it's nearly exhausted,
+// and retries idempotent requests failing with server errors or secondary rate limits
+type rateLimitTransport struct {
+	base http.RoundTripper
+	// quota names the quota tracked, in the logs and metrics
+	quota     string
+	lock      sync.Mutex
+	remaining int
+	reset     time.Time
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.waitForQuota(req); err != nil {
+		return nil, err
+	}
+
+	route := routeOf(req.URL)
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		metrics.GitHubAPICalls.WithLabelValues(req.Method, route).Inc()
+		resp, err := t.base.RoundTrip(req)
+		if err == nil {
+			t.track(resp)
+		}
+		if err != nil || resp.StatusCode >= 400 {
+			metrics.GitHubAPIErrors.WithLabelValues(req.Method, route).Inc()
+		}
+
+		if attempt >= maxRetries || !retryable(req) {
+			return resp, err
+		}
+
+		delay := backoff(attempt)
+		switch {
+		case err != nil:
+			klog.Warningf("Error on %s %s, retrying in %s: %s", req.Method, req.URL.Path, delay, err)
+		case resp.StatusCode >= 500:
+			klog.Warningf("Got %s on %s %s, retrying in %s", resp.Status, req.Method, req.URL.Path, delay)
+		case isSecondaryRateLimit(resp):
+			if retryAfter := retryAfterOf(resp); retryAfter > delay {
+				delay = retryAfter
+			}
+			klog.Warningf("Hit a secondary rate limit on %s %s, retrying in %s", req.Method, req.URL.Path, delay)
+		default:
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		metrics.GitHubAPIRetries.WithLabelValues(req.Method, route).Inc()
+
+		if err := sleep(req, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// waitForQuota delays the request until the quota is reset, if it's nearly exhausted
+func (t *rateLimitTransport) waitForQuota(req *http.Request) error {
+	t.lock.Lock()
+	remaining, reset := t.remaining, t.reset
+	t.lock.Unlock()
+
+	wait := time.Until(reset)
+	if remaining >= rateLimitReserve || wait <= 0 {
+		return nil
+	}
+
+	klog.Warningf("Only %d GitHub API requests remain for %s, waiting %s for the quota to be reset", remaining, t.quota,
+		wait.Round(time.Second))
+	return sleep(req, wait)
+}
+
+func (t *rateLimitTransport) track(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.remaining = remaining
+	metrics.GitHubRateLimitRemaining.WithLabelValues(t.quota).Set(float64(remaining))
+
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		t.reset = time.Unix(reset, 0)
+		metrics.GitHubRateLimitReset.WithLabelValues(t.quota).Set(float64(reset))
+	}
+
+	if limit, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Limit"), 10, 64); err == nil {
+		metrics.GitHubRateLimitLimit.WithLabelValues(t.quota).Set(float64(limit))
+	}
+}
+
+// routeParams names the parameter following a path segment in the API routes, a parameter is only assumed if the
+// next segment isn't a known segment itself, as in /issues/comments/{comment_id}
+var routeParams = map[string]string{
+	"issues":        "{issue_number}",
+	"pulls":         "{pull_number}",
+	"comments":      "{comment_id}",
+	"commits":       "{commit_sha}",
+	"runs":          "{run_id}",
+	"labels":        "{name}",
+	"collaborators": "{username}",
+	"users":         "{username}",
+	"contents":      "{path}",
+}
+
+// routeOf returns the template of the API route of a URL, like /repos/{owner}/{repo}/pulls/{pull_number}, so
+// metrics can tell the API calls apart without a series per repository or PR
+func routeOf(u *url.URL) string {
+	segments := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	route := []string{}
+	for i := 0; i < len(segments); i++ {
+		segment := segments[i]
+		switch {
+		case i == 0 && segment == "repos" && len(segments) >= 3:
+			route = append(route, segment, "{owner}", "{repo}")
+			i += 2
+			continue
+		case isNumber(segment):
+			segment = "{id}"
+		}
+		route = append(route, segment)
+
+		param, ok := routeParams[segment]
+		if !ok || i+1 >= len(segments) {
+			continue
+		}
+		if _, known := routeParams[segments[i+1]]; known {
+			continue
+		}
+
+		route = append(route, param)
+		i++
+		// Paths of files span the rest of the URL
+		if segment == "contents" {
+			break
+		}
+	}
+	return "/" + strings.Join(route, "/")
+}
+
+func isNumber(segment string) bool {
+	_, err := strconv.ParseUint(segment, 10, 64)
+	return err == nil
+}
+
+// retryable returns whether the request can be sent again without side effects
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	}
+	return false
+}
+
+func isSecondaryRateLimit(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		return false
+	}
+	if resp.Header.Get("Retry-After") != "" {
+		return true
+	}
+
+	// The body has to be put back, as it's read again if the request isn't retried
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return err == nil && strings.Contains(strings.ToLower(string(body)), "secondary rate limit")
+}
+
+func retryAfterOf(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// backoff returns an exponential delay for the attempt, with jitter so concurrent retries are spread
+func backoff(attempt int) time.Duration {
+	delay := baseBackoff << attempt
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+func sleep(req *http.Request, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
@@ -0,0 +1,129 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	ssh2 "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/oauth2"
+	"k8s.io/klog"
+
+	"github.com/submariner-io/submariner-bot/pkg/config"
+	"github.com/submariner-io/submariner-bot/pkg/ghclient"
+)
+
+// newAuth returns the auth for the remotes of a repository, and whether they must be accessed over https, which uses
+// the same token as the API. GitHub Apps can't use SSH keys, so when the bot runs as one https is always used.
+func newAuth(name string) (transport.AuthMethod, bool, error) {
+	app, err := config.GetGithubApp()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if app != nil || config.GetGitTransport() == config.GitTransportHTTPS {
+		owner, repo, found := strings.Cut(name, "/")
+		if !found {
+			return nil, false, fmt.Errorf("%q isn't a full repository name", name)
+		}
+
+		source, err := ghclient.TokenSource(owner, repo)
+		if err != nil {
+			return nil, false, err
+		}
+		return &tokenAuth{source: source}, true, nil
+	}
+
+	signer, err := config.GetSSHKey()
+	if err != nil {
+		return nil, false, err
+	}
+
+	callback, err := hostKeyCallback()
+	if err != nil {
+		return nil, false, err
+	}
+
+	auth := &ssh2.PublicKeys{User: "git", Signer: signer}
+	auth.HostKeyCallback = callback
+	return auth, false, nil
+}
+
+// hostKeyCallback verifies the SSH host keys against the configured known hosts
+func hostKeyCallback() (ssh.HostKeyCallback, error) {
+	knownHosts, err := config.GetSSHKnownHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	// knownhosts only reads files, it's done with the file once it returns. Repositories are initialized
+	// concurrently, so each call gets its own file.
+	file, err := os.CreateTemp("", "submariner-bot-known_hosts")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(knownHosts)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	callback, err := knownhosts.New(file.Name())
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the SSH known hosts: %s", err)
+	}
+
+	// KeyErrors are still wrapped, go-git relies on them to find the key algorithms to use for each host
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+
+		if len(keyErr.Want) == 0 {
+			return fmt.Errorf("host %s isn't in the SSH known hosts: %w", hostname, err)
+		}
+
+		for _, want := range keyErr.Want {
+			if want.Key.Type() == key.Type() {
+				klog.Errorf("The %s host key of %s is %s, which doesn't match the known one, the connection may be intercepted",
+					key.Type(), hostname, ssh.FingerprintSHA256(key))
+				return fmt.Errorf("the %s host key of %s doesn't match the SSH known hosts: %w", key.Type(), hostname, err)
+			}
+		}
+		return err
+	}, nil
+}
+
+// tokenAuth authenticates https requests with a token, taken from the source on every request so it's refreshed
+type tokenAuth struct {
+	source oauth2.TokenSource
+}
+
+func (a *tokenAuth) Name() string {
+	return "http-token-auth"
+}
+
+func (a *tokenAuth) String() string {
+	return a.Name()
+}
+
+func (a *tokenAuth) SetAuth(r *http.Request) {
+	token, err := a.source.Token()
+	if err != nil {
+		klog.Errorf("Error getting a token for %s: %s", r.URL.Host, err)
+		return
+	}
+	r.SetBasicAuth("x-access-token", token.AccessToken)
+}
@@ -0,0 +1,337 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"k8s.io/klog"
+)
+
+const (
+	botName  = "submariner-bot"
+	botEmail = "submariner-bot@users.noreply.github.com"
+)
+
+// ConflictError is returned when a commit can't be cherry-picked because its changes overlap with different
+// changes made to the same lines in the target
+type ConflictError struct {
+	Commit string
+	Files  []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("commit %s conflicts on %s", e.Commit, strings.Join(e.Files, ", "))
+}
+
+// treeFiles maps the path of every non-directory entry in a tree to its entry
+type treeFiles map[string]object.TreeEntry
+
+// MergedCommits returns the commits a merged PR added to its base, oldest first. For merge commits and squashed
+// PRs that's the merge commit itself, for rebased PRs those are the commits in the first parent chain matching
+// the messages of the PR commits.
+func (g *Git) MergedCommits(mergeSHA string, prMessages []string) ([]string, error) {
+	commit, err := g.repo.CommitObject(plumbing.NewHash(mergeSHA))
+	if err != nil {
+		return nil, err
+	}
+
+	if commit.NumParents() > 1 || len(prMessages) <= 1 {
+		return []string{mergeSHA}, nil
+	}
+
+	chain := []string{}
+	for i := len(prMessages) - 1; i >= 0; i-- {
+		if strings.TrimSpace(commit.Message) != strings.TrimSpace(prMessages[i]) {
+			klog.Infof("Commit %s doesn't match the PR commits, assuming it was squashed", mergeSHA)
+			return []string{mergeSHA}, nil
+		}
+		chain = append([]string{commit.Hash.String()}, chain...)
+
+		if i > 0 {
+			if commit, err = commit.Parent(0); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return chain, nil
+}
+
+// CherryPick creates a local branch starting at baseSHA with the changes of each commit applied on top, in order.
+// Files changed in the target too are merged line by line, a ConflictError is returned if the changes overlap.
+func (g *Git) CherryPick(branch, baseSHA string, commits []string) error {
+	head, err := g.repo.CommitObject(plumbing.NewHash(baseSHA))
+	if err != nil {
+		return err
+	}
+
+	for _, sha := range commits {
+		head, err = g.cherryPickCommit(head, sha)
+		if err != nil {
+			return err
+		}
+	}
+
+	return g.CreateBranch(branch, head.Hash.String())
+}
+
+func (g *Git) cherryPickCommit(head *object.Commit, sha string) (*object.Commit, error) {
+	commit, err := g.repo.CommitObject(plumbing.NewHash(sha))
+	if err != nil {
+		return nil, err
+	}
+
+	if commit.NumParents() == 0 {
+		return nil, fmt.Errorf("commit %s has no parent to compare with", sha)
+	}
+
+	// For merge commits, the changes are the ones brought to the first parent
+	parent, err := commit.Parent(0)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := diffCommits(parent, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	headTree, err := head.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := flattenTree(headTree)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []string{}
+	for _, change := range changes {
+		from, to := change.From, change.To
+		current, exists := files[pathOf(change)]
+
+		switch {
+		case to.Name != "" && exists && current.Hash == to.TreeEntry.Hash && current.Mode == to.TreeEntry.Mode:
+			// Already as the commit leaves it
+		case to.Name == "" && !exists:
+			// Already deleted
+		case from.Name == "" && exists:
+			conflicts = append(conflicts, to.Name)
+		case from.Name != "" && !exists:
+			conflicts = append(conflicts, from.Name)
+		case to.Name == "" && current.Hash != from.TreeEntry.Hash:
+			conflicts = append(conflicts, from.Name)
+		case to.Name == "":
+			delete(files, from.Name)
+		case current.Hash != from.TreeEntry.Hash:
+			entry, err := g.mergeFile(from.TreeEntry, current, to.TreeEntry)
+			if err != nil {
+				return nil, err
+			}
+			if entry == nil {
+				conflicts = append(conflicts, to.Name)
+				continue
+			}
+			files[to.Name] = *entry
+		default:
+			files[to.Name] = object.TreeEntry{Name: path.Base(to.Name), Mode: to.TreeEntry.Mode, Hash: to.TreeEntry.Hash}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, &ConflictError{Commit: sha, Files: conflicts}
+	}
+
+	treeHash, err := g.writeTree(files, "")
+	if err != nil {
+		return nil, err
+	}
+
+	picked := &object.Commit{
+		Author: commit.Author,
+		Committer: object.Signature{
+			Name:  botName,
+			Email: botEmail,
+			When:  time.Now(),
+		},
+		Message:      fmt.Sprintf("%s\n\n(cherry picked from commit %s)\n", strings.TrimRight(commit.Message, "\n"), sha),
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{head.Hash},
+	}
+
+	obj := g.repo.Storer.NewEncodedObject()
+	if err := picked.Encode(obj); err != nil {
+		return nil, err
+	}
+	hash, err := g.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	klog.Infof("Cherry-picked %s as %s", sha, hash)
+	return g.repo.CommitObject(hash)
+}
+
+// mergeFile merges the changes from base to theirs into ours, returning the merged entry, or nil if they conflict
+func (g *Git) mergeFile(base, ours, theirs object.TreeEntry) (*object.TreeEntry, error) {
+	// Only the contents of files can be merged, not symlinks or submodules
+	for _, entry := range []object.TreeEntry{base, ours, theirs} {
+		if !entry.Mode.IsFile() || entry.Mode == filemode.Symlink {
+			return nil, nil
+		}
+	}
+
+	// A mode change is kept unless both sides changed the mode differently
+	mode := ours.Mode
+	if theirs.Mode != base.Mode {
+		if ours.Mode != base.Mode && ours.Mode != theirs.Mode {
+			return nil, nil
+		}
+		mode = theirs.Mode
+	}
+
+	contents := [][]byte{}
+	for _, hash := range []plumbing.Hash{base.Hash, ours.Hash, theirs.Hash} {
+		content, err := g.readBlob(hash)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+
+	merged, ok := merge3(contents[0], contents[1], contents[2])
+	if !ok {
+		return nil, nil
+	}
+
+	obj := g.repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	writer, err := obj.Writer()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(merged); err != nil {
+		writer.Close()
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	hash, err := g.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &object.TreeEntry{Name: ours.Name, Mode: mode, Hash: hash}, nil
+}
+
+func (g *Git) readBlob(hash plumbing.Hash) ([]byte, error) {
+	blob, err := g.repo.BlobObject(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+func diffCommits(from, to *object.Commit) (object.Changes, error) {
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.DiffTree(fromTree, toTree)
+}
+
+func pathOf(change *object.Change) string {
+	if change.To.Name != "" {
+		return change.To.Name
+	}
+	return change.From.Name
+}
+
+func flattenTree(tree *object.Tree) (treeFiles, error) {
+	files := make(treeFiles)
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			return files, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if entry.Mode != filemode.Dir {
+			files[name] = entry
+		}
+	}
+}
+
+// writeTree stores the tree objects for the files under dir, returning the hash of the tree for dir
+func (g *Git) writeTree(files treeFiles, dir string) (plumbing.Hash, error) {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	entries := []object.TreeEntry{}
+	subdirs := make(map[string]bool)
+	for name, entry := range files {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		rest := strings.TrimPrefix(name, prefix)
+		if i := strings.Index(rest, "/"); i >= 0 {
+			subdirs[rest[:i]] = true
+		} else {
+			entries = append(entries, entry)
+		}
+	}
+
+	for subdir := range subdirs {
+		hash, err := g.writeTree(files, prefix+subdir)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entries = append(entries, object.TreeEntry{Name: subdir, Mode: filemode.Dir, Hash: hash})
+	}
+
+	// git sorts entries comparing directories as if they had a trailing slash
+	sort.Slice(entries, func(i, j int) bool {
+		return sortName(entries[i]) < sortName(entries[j])
+	})
+
+	obj := g.repo.Storer.NewEncodedObject()
+	if err := (&object.Tree{Entries: entries}).Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return g.repo.Storer.SetEncodedObject(obj)
+}
+
+func sortName(entry object.TreeEntry) string {
+	if entry.Mode == filemode.Dir {
+		return entry.Name + "/"
+	}
+	return entry.Name
+}
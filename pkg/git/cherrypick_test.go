@@ -0,0 +1,351 @@
+package git
+
+import (
+	"errors"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// testFile is the content and mode of a file in a test commit, files are regular unless a mode is set
+type testFile struct {
+	content string
+	mode    filemode.FileMode
+}
+
+type testFiles map[string]testFile
+
+func TestCherryPickCommit(t *testing.T) {
+	base := testFiles{
+		"README.md":       {content: "readme\n"},
+		"a.txt":           {content: "1\n2\n3\n4\n5\n"},
+		"script.sh":       {content: "echo\n"},
+		"dir/sub/code.go": {content: "package sub\n\nfunc A() {}\n"},
+	}
+
+	tests := []struct {
+		name string
+		// commit is the files after the commit cherry-picked, whose parent has the base files
+		commit testFiles
+		// head is the files of the commit cherry-picked onto, whose parent has the base files
+		head      testFiles
+		expected  testFiles
+		conflicts []string
+	}{
+		{
+			name:     "add",
+			commit:   with(base, "new.txt", testFile{content: "new\n"}),
+			head:     with(base, "README.md", testFile{content: "changed\n"}),
+			expected: with(with(base, "README.md", testFile{content: "changed\n"}), "new.txt", testFile{content: "new\n"}),
+		},
+		{
+			name:     "add already done",
+			commit:   with(base, "new.txt", testFile{content: "new\n"}),
+			head:     with(base, "new.txt", testFile{content: "new\n"}),
+			expected: with(base, "new.txt", testFile{content: "new\n"}),
+		},
+		{
+			name:      "add conflict",
+			commit:    with(base, "new.txt", testFile{content: "new\n"}),
+			head:      with(base, "new.txt", testFile{content: "other\n"}),
+			conflicts: []string{"new.txt"},
+		},
+		{
+			name:     "delete",
+			commit:   without(base, "a.txt"),
+			head:     base,
+			expected: without(base, "a.txt"),
+		},
+		{
+			name:     "delete already done",
+			commit:   without(base, "a.txt"),
+			head:     without(base, "a.txt"),
+			expected: without(base, "a.txt"),
+		},
+		{
+			name:      "delete conflict",
+			commit:    without(base, "a.txt"),
+			head:      with(base, "a.txt", testFile{content: "1\n2\n3\n4\nfive\n"}),
+			conflicts: []string{"a.txt"},
+		},
+		{
+			name:     "modify",
+			commit:   with(base, "a.txt", testFile{content: "one\n2\n3\n4\n5\n"}),
+			head:     base,
+			expected: with(base, "a.txt", testFile{content: "one\n2\n3\n4\n5\n"}),
+		},
+		{
+			name:     "modify merged with the head",
+			commit:   with(base, "a.txt", testFile{content: "one\n2\n3\n4\n5\n"}),
+			head:     with(base, "a.txt", testFile{content: "1\n2\n3\n4\nfive\n"}),
+			expected: with(base, "a.txt", testFile{content: "one\n2\n3\n4\nfive\n"}),
+		},
+		{
+			name:      "modify conflict",
+			commit:    with(base, "a.txt", testFile{content: "one\n2\n3\n4\n5\n"}),
+			head:      with(base, "a.txt", testFile{content: "uno\n2\n3\n4\n5\n"}),
+			conflicts: []string{"a.txt"},
+		},
+		{
+			name:      "modify deleted in the head",
+			commit:    with(base, "a.txt", testFile{content: "one\n2\n3\n4\n5\n"}),
+			head:      without(base, "a.txt"),
+			conflicts: []string{"a.txt"},
+		},
+		{
+			name:     "mode change",
+			commit:   with(base, "script.sh", testFile{content: "echo\n", mode: filemode.Executable}),
+			head:     base,
+			expected: with(base, "script.sh", testFile{content: "echo\n", mode: filemode.Executable}),
+		},
+		{
+			name:     "mode change merged with a content change",
+			commit:   with(base, "script.sh", testFile{content: "echo\n", mode: filemode.Executable}),
+			head:     with(base, "script.sh", testFile{content: "echo hello\n"}),
+			expected: with(base, "script.sh", testFile{content: "echo hello\n", mode: filemode.Executable}),
+		},
+		{
+			name:      "mode change conflict with a symlink",
+			commit:    with(base, "script.sh", testFile{content: "echo\n", mode: filemode.Executable}),
+			head:      with(base, "script.sh", testFile{content: "other.sh", mode: filemode.Symlink}),
+			conflicts: []string{"script.sh"},
+		},
+		{
+			name: "nested directories",
+			commit: with(with(base, "dir/sub/code.go", testFile{content: "package sub\n\nfunc A() {}\n\nfunc B() {}\n"}),
+				"dir/other/new.go", testFile{content: "package other\n"}),
+			head: with(base, "dir/sub/more.go", testFile{content: "package sub\n"}),
+			expected: with(with(with(base, "dir/sub/code.go", testFile{content: "package sub\n\nfunc A() {}\n\nfunc B() {}\n"}),
+				"dir/other/new.go", testFile{content: "package other\n"}), "dir/sub/more.go", testFile{content: "package sub\n"}),
+		},
+		{
+			name:      "several conflicts",
+			commit:    without(with(base, "a.txt", testFile{content: "one\n2\n3\n4\n5\n"}), "dir/sub/code.go"),
+			head:      with(without(base, "a.txt"), "dir/sub/code.go", testFile{content: "package sub\n"}),
+			conflicts: []string{"a.txt", "dir/sub/code.go"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := newMemoryGit(t)
+			parent := commitFiles(t, g, "Base", base)
+			commit := commitFiles(t, g, "Change\n\nDetails\n", test.commit, parent)
+			head := commitFiles(t, g, "Head", test.head, parent)
+
+			picked, err := g.cherryPickCommit(head, commit.Hash.String())
+			if test.conflicts != nil {
+				conflict := &ConflictError{}
+				if !errors.As(err, &conflict) {
+					t.Fatalf("cherryPickCommit returned %v, expected a conflict", err)
+				}
+				if conflict.Commit != commit.Hash.String() || !sameFiles(conflict.Files, test.conflicts) {
+					t.Fatalf("cherryPickCommit returned the conflict %v, expected %s conflicting on %v", conflict,
+						commit.Hash, test.conflicts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cherryPickCommit failed: %s", err)
+			}
+
+			if files := readFiles(t, g, picked); !reflect.DeepEqual(files, normalize(test.expected)) {
+				t.Fatalf("The cherry-picked commit has the files %v, expected %v", files, normalize(test.expected))
+			}
+			if len(picked.ParentHashes) != 1 || picked.ParentHashes[0] != head.Hash {
+				t.Fatalf("The cherry-picked commit has the parents %v, expected %s", picked.ParentHashes, head.Hash)
+			}
+			expectedMessage := "Change\n\nDetails\n\n(cherry picked from commit " + commit.Hash.String() + ")\n"
+			if picked.Message != expectedMessage {
+				t.Fatalf("The cherry-picked commit has the message %q, expected %q", picked.Message, expectedMessage)
+			}
+		})
+	}
+}
+
+func TestMergedCommits(t *testing.T) {
+	g := newMemoryGit(t)
+	base := commitFiles(t, g, "Base", testFiles{"a.txt": {content: "a\n"}})
+
+	first := commitFiles(t, g, "First change", testFiles{"a.txt": {content: "b\n"}}, base)
+	second := commitFiles(t, g, "Second change\n\nDetails", testFiles{"a.txt": {content: "c\n"}}, first)
+	merge := commitFiles(t, g, "Merge pull request #1", testFiles{"a.txt": {content: "c\n"}}, base, second)
+	squash := commitFiles(t, g, "Squashed changes (#1)", testFiles{"a.txt": {content: "c\n"}}, base)
+	rebasedFirst := commitFiles(t, g, "First change\n", testFiles{"a.txt": {content: "b\n"}}, base)
+	rebasedSecond := commitFiles(t, g, "Second change\n\nDetails\n", testFiles{"a.txt": {content: "c\n"}}, rebasedFirst)
+	single := commitFiles(t, g, "Single change", testFiles{"a.txt": {content: "b\n"}}, base)
+	prMessages := []string{"First change", "Second change\n\nDetails"}
+
+	tests := []struct {
+		name     string
+		merged   *object.Commit
+		messages []string
+		expected []*object.Commit
+	}{
+		{name: "merge commit", merged: merge, messages: prMessages, expected: []*object.Commit{merge}},
+		{name: "squash", merged: squash, messages: prMessages, expected: []*object.Commit{squash}},
+		{
+			name:     "rebase",
+			merged:   rebasedSecond,
+			messages: prMessages,
+			expected: []*object.Commit{rebasedFirst, rebasedSecond},
+		},
+		{name: "single commit", merged: single, messages: []string{"Single change"}, expected: []*object.Commit{single}},
+		{
+			name:     "rebase of edited commits",
+			merged:   rebasedSecond,
+			messages: []string{"First change", "Second change, edited"},
+			expected: []*object.Commit{rebasedSecond},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			commits, err := g.MergedCommits(test.merged.Hash.String(), test.messages)
+			if err != nil {
+				t.Fatalf("MergedCommits failed: %s", err)
+			}
+
+			expected := []string{}
+			for _, commit := range test.expected {
+				expected = append(expected, commit.Hash.String())
+			}
+			if !reflect.DeepEqual(commits, expected) {
+				t.Fatalf("MergedCommits returned %v, expected %v", commits, expected)
+			}
+		})
+	}
+}
+
+func newMemoryGit(t *testing.T) *Git {
+	repo, err := gogit.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Git{repo: repo, name: "submariner-bot-test/memory"}
+}
+
+// commitFiles stores a commit with the given files and parents
+func commitFiles(t *testing.T, g *Git, message string, files testFiles, parents ...*object.Commit) *object.Commit {
+	entries := make(treeFiles)
+	for name, file := range files {
+		obj := g.repo.Storer.NewEncodedObject()
+		obj.SetType(plumbing.BlobObject)
+		writer, err := obj.Writer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(file.content)); err != nil {
+			t.Fatal(err)
+		}
+		writer.Close()
+
+		hash, err := g.repo.Storer.SetEncodedObject(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[name] = object.TreeEntry{Name: path.Base(name), Mode: modeOf(file), Hash: hash}
+	}
+
+	treeHash, err := g.writeTree(entries, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	commit := &object.Commit{Author: signature, Committer: signature, Message: message, TreeHash: treeHash}
+	for _, parent := range parents {
+		commit.ParentHashes = append(commit.ParentHashes, parent.Hash)
+	}
+
+	obj := g.repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := g.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit, err = g.repo.CommitObject(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return commit
+}
+
+// readFiles returns the files of a commit, with their modes set
+func readFiles(t *testing.T, g *Git, commit *object.Commit) testFiles {
+	tree, err := commit.Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := flattenTree(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := testFiles{}
+	for name, entry := range entries {
+		content, err := g.readBlob(entry.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = testFile{content: string(content), mode: entry.Mode}
+	}
+	return files
+}
+
+// with returns a copy of files with the given file added or replaced
+func with(files testFiles, name string, file testFile) testFiles {
+	result := testFiles{name: file}
+	for n, f := range files {
+		if n != name {
+			result[n] = f
+		}
+	}
+	return result
+}
+
+// without returns a copy of files without the given file
+func without(files testFiles, name string) testFiles {
+	result := testFiles{}
+	for n, f := range files {
+		if n != name {
+			result[n] = f
+		}
+	}
+	return result
+}
+
+// normalize returns a copy of files with their modes set
+func normalize(files testFiles) testFiles {
+	result := testFiles{}
+	for name, file := range files {
+		result[name] = testFile{content: file.content, mode: modeOf(file)}
+	}
+	return result
+}
+
+func modeOf(file testFile) filemode.FileMode {
+	if file.mode == filemode.Empty {
+		return filemode.Regular
+	}
+	return file.mode
+}
+
+func sameFiles(files, expected []string) bool {
+	files, expected = append([]string{}, files...), append([]string{}, expected...)
+	sort.Strings(files)
+	sort.Strings(expected)
+	return reflect.DeepEqual(files, expected)
+}
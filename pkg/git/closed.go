@@ -0,0 +1,139 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	gogitConfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"k8s.io/klog"
+)
+
+// closedRefsPrefix returns where the heads of the branches deleted when a PR is closed are kept on origin, so the
+// branches can be restored if the PR is reopened
+func closedRefsPrefix(prNum int) string {
+	return fmt.Sprintf("refs/closed/%d/", prNum)
+}
+
+// ArchiveBranches pushes the heads of the branches of a closed PR to refs/closed/<N>/<branch> on origin, before they
+// are deleted
+func (git *Git) ArchiveBranches(prNum int, branches Branches) error {
+	if len(branches) == 0 {
+		return nil
+	}
+
+	prefix := closedRefsPrefix(prNum)
+	// The local references are only used to push, and references left from before could conflict with them
+	defer func() {
+		if err := git.removeClosedRefs(prNum); err != nil {
+			klog.Errorf("Error removing the local references under %s: %s", prefix, err)
+		}
+	}()
+	if err := git.removeClosedRefs(prNum); err != nil {
+		return err
+	}
+
+	refSpecs := []gogitConfig.RefSpec{}
+	for branch, hash := range branches {
+		ref := plumbing.ReferenceName(prefix + branch)
+		if err := git.repo.Storer.SetReference(plumbing.NewHashReference(ref, *hash)); err != nil {
+			return err
+		}
+		refSpecs = append(refSpecs, gogitConfig.RefSpec(fmt.Sprintf("+%s:%s", ref, ref)))
+	}
+
+	start := time.Now()
+	err := git.repo.Push(&gogit.PushOptions{RemoteName: Origin, Auth: git.auth, RefSpecs: refSpecs})
+	observeDuration("push", start)
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return err
+	}
+
+	klog.Infof("Kept the heads of %d branches under %s", len(branches), prefix)
+	return nil
+}
+
+// FetchClosedBranches fetches the heads kept by ArchiveBranches for a PR, returning them by branch
+func (git *Git) FetchClosedBranches(prNum int) (Branches, error) {
+	prefix := closedRefsPrefix(prNum)
+	if err := git.removeClosedRefs(prNum); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	err := git.repo.Fetch(&gogit.FetchOptions{
+		RemoteName: Origin,
+		RefSpecs:   []gogitConfig.RefSpec{gogitConfig.RefSpec(fmt.Sprintf("+%s*:%s*", prefix, prefix))},
+		Auth:       git.auth,
+		Tags:       gogit.NoTags,
+	})
+	observeDuration("fetch", start)
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return nil, err
+	}
+
+	refs, err := git.repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make(Branches)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if name := ref.Name().String(); strings.HasPrefix(name, prefix) {
+			hash := ref.Hash()
+			branches[strings.TrimPrefix(name, prefix)] = &hash
+		}
+		return nil
+	})
+	return branches, err
+}
+
+// DeleteClosedBranches deletes the heads kept by ArchiveBranches for the given branches of a PR, from origin and
+// from the local repository
+func (git *Git) DeleteClosedBranches(prNum int, branches []string) error {
+	prefix := closedRefsPrefix(prNum)
+	refSpecs := []gogitConfig.RefSpec{}
+	for _, branch := range branches {
+		refSpecs = append(refSpecs, gogitConfig.RefSpec(":"+prefix+branch))
+	}
+
+	if len(refSpecs) > 0 {
+		start := time.Now()
+		err := git.repo.Push(&gogit.PushOptions{RemoteName: Origin, Auth: git.auth, RefSpecs: refSpecs})
+		observeDuration("push", start)
+		if err != nil && err != gogit.NoErrAlreadyUpToDate {
+			return err
+		}
+	}
+
+	return git.removeClosedRefs(prNum)
+}
+
+// removeClosedRefs removes the local references under closedRefsPrefix for a PR
+func (git *Git) removeClosedRefs(prNum int) error {
+	prefix := closedRefsPrefix(prNum)
+	refs, err := git.repo.References()
+	if err != nil {
+		return err
+	}
+
+	names := []plumbing.ReferenceName{}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), prefix) {
+			names = append(names, ref.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := git.repo.Storer.RemoveReference(name); err != nil {
+			return err
+		}
+	}
+	return git.removeEmptyRefDirs("refs/closed")
+}
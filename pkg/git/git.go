@@ -5,24 +5,29 @@ package git
 import (
 	"encoding/hex"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	gogit "github.com/go-git/go-git/v5"
 	gogitConfig "github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/plumbing/transport"
-	ssh2 "github.com/go-git/go-git/v5/plumbing/transport/ssh"
-	"golang.org/x/crypto/ssh"
 	"k8s.io/klog"
 
-	"github.com/submariner-io/submariner-bot/pkg/config"
+	"github.com/submariner-io/submariner-bot/pkg/metrics"
 )
 
 const Origin = "origin"
 
 var (
+	// projectsLock only protects the map, each Git is initialized and fetched under its own lock
 	projectsLock sync.Mutex
 	projects     map[string]*Git = make(map[string]*Git)
 )
@@ -32,58 +37,105 @@ type Git struct {
 	name string
 	url  string
 	auth transport.AuthMethod
-	lock sync.Mutex
+	// https is set when the remotes are accessed over https instead of SSH
+	https bool
+	lock  sync.Mutex
+	// lastUsed is when the repository was last requested, the least recently used clones are removed first
+	lastUsed time.Time
 }
 
-func New(name, url string) (*Git, error) {
+// New returns the repository with the given full name, cloning it from sshURL or cloneURL depending on the auth
+func New(name, sshURL, cloneURL string) (*Git, error) {
+	git := getProject(name)
+
+	git.Lock()
+	defer git.Unlock()
+
+	git.lastUsed = time.Now()
+
+	// A failed initialization is retried by the next call
+	if git.repo == nil {
+		if err := git.init(sshURL, cloneURL); err != nil {
+			return nil, err
+		}
+	}
+
+	err := git.EnsureAndFetchOrigin()
+	return git, err
+}
+
+// getProject returns the Git for a repository, adding an uninitialized one if it's not known yet
+func getProject(name string) *Git {
 	projectsLock.Lock()
 	defer projectsLock.Unlock()
 
-	if val, ok := projects[name]; ok {
-		val.Lock()
-		defer val.Unlock()
-		err := val.EnsureAndFetchOrigin()
-		return val, err
+	git, ok := projects[name]
+	if !ok {
+		git = &Git{name: name}
+		projects[name] = git
 	}
+	return git
+}
 
-	signer, err := config.GetSSHKey()
+func (git *Git) init(sshURL, cloneURL string) error {
+	auth, https, err := newAuth(git.name)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	dirName := dirName(name)
+	dirName := dirName(git.name)
 
-	auth := &ssh2.PublicKeys{User: "git", Signer: signer}
-	auth.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	url := sshURL
+	if https {
+		url = cloneURL
+	}
 
-	repo, err := gogit.PlainClone(dirName, false, &gogit.CloneOptions{Auth: auth, URL: url})
+	// Nothing needs a worktree, files are read from the object store
+	start := time.Now()
+	repo, err := gogit.PlainClone(dirName, true, &gogit.CloneOptions{Auth: auth, URL: url})
 	if err == nil {
-		klog.Infof("Repo %s cloned to %s from %s", name, dirName, url)
+		observeDuration("clone", start)
+		klog.Infof("Repo %s cloned to %s from %s", git.name, dirName, url)
 	} else if err != nil && err.Error() == "repository already exists" {
 		repo, err = gogit.PlainOpen(dirName)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		klog.Infof("Repo %s from disk: %s", name, dirName)
+		klog.Infof("Repo %s from disk: %s", git.name, dirName)
 	} else {
-		return nil, err
+		observeDuration("clone", start)
+		return err
 	}
 
-	git := &Git{repo: repo, url: url, name: name, auth: auth}
-
-	err = git.EnsureAndFetchOrigin()
-	projects[name] = git
-
-	return git, err
+	git.repo, git.url, git.auth, git.https = repo, url, auth, https
+	return nil
 }
 
 func (git *Git) EnsureAndFetchOrigin() error {
 	return git.EnsureAndFetch(Origin, git.url)
 }
 
+// RemoteURL returns which of the URLs of a repository its remote must use
+func (git *Git) RemoteURL(sshURL, cloneURL string) string {
+	if git.https {
+		return cloneURL
+	}
+	return sshURL
+}
+
+// GitHubURLs returns the SSH and https URLs of a GitHub repository from its full name
+func GitHubURLs(name string) (sshURL, cloneURL string) {
+	return fmt.Sprintf("git@github.com:%s.git", name), fmt.Sprintf("https://github.com/%s.git", name)
+}
+
 func dirName(name string) string {
 	return path.Join("/tmp", "git", name)
 }
 
+// observeDuration records how long a git operation which started at start took
+func observeDuration(operation string, start time.Time) {
+	metrics.GitOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
 func (git *Git) Lock() {
 	git.lock.Lock()
 }
@@ -92,19 +144,37 @@ func (git *Git) Unlock() {
 	git.lock.Unlock()
 }
 
-func (git *Git) EnsureAndFetch(name, url string) error {
+// EnsureAndFetch (re)creates a remote and fetches it, only fetching the given branches if there are any
+func (git *Git) EnsureAndFetch(name, url string, branches ...string) error {
 	if err := git.repo.DeleteRemote(name); err != nil {
 		if err != gogit.ErrRemoteNotFound {
 			return err
 		}
 	}
-	_, err := git.repo.CreateRemote(&gogitConfig.RemoteConfig{Name: name, URLs: []string{url}})
+
+	remoteConfig := &gogitConfig.RemoteConfig{Name: name, URLs: []string{url}}
+	for _, branch := range branches {
+		remoteConfig.Fetch = append(remoteConfig.Fetch,
+			gogitConfig.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", branch, name, branch)))
+	}
+
+	_, err := git.repo.CreateRemote(remoteConfig)
 	if err != nil {
 		return err
 	}
 	klog.Infof("Remote %s ensured from %s", name, url)
 
-	err = git.repo.Fetch(&gogit.FetchOptions{RemoteName: name, Auth: git.auth})
+	fetchOptions := &gogit.FetchOptions{RemoteName: name, Auth: git.auth}
+	// Tags are needed to find the changes included in releases, forks' tags aren't trusted
+	if name == Origin {
+		fetchOptions.Tags = gogit.AllTags
+	} else {
+		fetchOptions.Tags = gogit.NoTags
+	}
+
+	start := time.Now()
+	err = git.repo.Fetch(fetchOptions)
+	observeDuration("fetch", start)
 	if err == nil || err.Error() == "already up-to-date" {
 		klog.Infof("Remote %s fetched", name)
 		return nil
@@ -114,6 +184,58 @@ func (git *Git) EnsureAndFetch(name, url string) error {
 	return err
 }
 
+// FetchPullHead fetches the head of a PR from origin, where GitHub keeps it as refs/pull/<N>/head, and checks
+// that the given commit was fetched
+func (git *Git) FetchPullHead(prNum int, sha string) error {
+	ref := fmt.Sprintf("refs/pull/%d/head", prNum)
+	start := time.Now()
+	err := git.repo.Fetch(&gogit.FetchOptions{
+		RemoteName: Origin,
+		RefSpecs:   []gogitConfig.RefSpec{gogitConfig.RefSpec(fmt.Sprintf("+%s:%s", ref, ref))},
+		Auth:       git.auth,
+		Tags:       gogit.NoTags,
+	})
+	observeDuration("fetch", start)
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return err
+	}
+
+	if _, err := git.repo.CommitObject(plumbing.NewHash(sha)); err != nil {
+		return fmt.Errorf("commit %s wasn't fetched with %s: %s", sha, ref, err)
+	}
+
+	klog.Infof("Fetched %s at %s", ref, sha)
+	return nil
+}
+
+// RemoveRemote removes a remote along with its remote-tracking branches
+func (git *Git) RemoveRemote(name string) error {
+	if name == Origin {
+		return fmt.Errorf("%s can't be removed", Origin)
+	}
+
+	if err := git.repo.DeleteRemote(name); err != nil && err != gogit.ErrRemoteNotFound {
+		return err
+	}
+
+	refs, err := git.repo.References()
+	if err != nil {
+		return err
+	}
+
+	prefix := "refs/remotes/" + name + "/"
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), prefix) {
+			return git.repo.Storer.RemoveReference(ref.Name())
+		}
+		return nil
+	})
+	if err == nil {
+		klog.Infof("Remote %s removed", name)
+	}
+	return err
+}
+
 type Branches map[string]*plumbing.Hash
 
 func (git *Git) GetBranches() (Branches, error) {
@@ -141,12 +263,19 @@ func (git *Git) GetBranches() (Branches, error) {
 	return branches, nil
 }
 
+// CreateBranch creates or moves a local branch, local branches are only used to push so the ones which conflict with
+// it, like a/b when creating a/b/1, are removed
 func (gitRepo *Git) CreateBranch(branch, sha string) error {
 	ref := plumbing.NewBranchReferenceName(branch)
 	refHash, err := getHash(sha)
 	if err != nil {
 		return err
 	}
+
+	if err := gitRepo.removeConflictingBranches(ref); err != nil {
+		return err
+	}
+
 	hr := plumbing.NewHashReference(ref, refHash)
 	err = gitRepo.repo.Storer.SetReference(hr)
 	if err != nil {
@@ -155,6 +284,51 @@ func (gitRepo *Git) CreateBranch(branch, sha string) error {
 	return err
 }
 
+func (gitRepo *Git) removeConflictingBranches(ref plumbing.ReferenceName) error {
+	refs, err := gitRepo.repo.Branches()
+	if err != nil {
+		return err
+	}
+
+	err = refs.ForEach(func(other *plumbing.Reference) error {
+		name := other.Name().String()
+		if strings.HasPrefix(name, ref.String()+"/") || strings.HasPrefix(ref.String(), name+"/") {
+			return gitRepo.repo.Storer.RemoveReference(other.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return gitRepo.removeEmptyRefDirs("refs/heads")
+}
+
+// removeEmptyRefDirs removes the directories left empty under dir by removed references, a directory
+// keeps a reference with the same name from being created
+func (gitRepo *Git) removeEmptyRefDirs(dir string) error {
+	root := path.Join(dirName(gitRepo.name), dir)
+	dirs := []string{}
+	err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && name != root {
+			dirs = append(dirs, name)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Children come after their parents in the walk, and removing a directory which isn't empty fails
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+	return nil
+}
+
 func getHash(sha string) (plumbing.Hash, error) {
 	hash, _ := hex.DecodeString(sha)
 	var refHash plumbing.Hash
@@ -175,6 +349,23 @@ func (gitRepo *Git) Push(branch string) error {
 			gogitConfig.RefSpec(fmt.Sprintf("+%s:%s", ref, ref)),
 		},
 	}
+	defer observeDuration("push", time.Now())
+	return gitRepo.repo.Push(&pushOptions)
+}
+
+// PushToRemote pushes a local branch over a branch of another remote, as long as that branch is still at expectedSHA
+func (gitRepo *Git) PushToRemote(remote, branch, remoteBranch, expectedSHA string) error {
+	ref := plumbing.NewBranchReferenceName(branch)
+	remoteRef := plumbing.NewBranchReferenceName(remoteBranch)
+	pushOptions := gogit.PushOptions{
+		RemoteName: remote,
+		Auth:       gitRepo.auth,
+		RefSpecs: []gogitConfig.RefSpec{
+			gogitConfig.RefSpec(fmt.Sprintf("+%s:%s", ref, remoteRef)),
+		},
+		ForceWithLease: &gogit.ForceWithLease{RefName: remoteRef, Hash: plumbing.NewHash(expectedSHA)},
+	}
+	defer observeDuration("push", time.Now())
 	return gitRepo.repo.Push(&pushOptions)
 }
 
@@ -196,27 +387,159 @@ func (gitRepo *Git) DeleteRemoteBranches(branches []string) error {
 	if err != nil {
 		return err
 	}
-	return origin.Push(&pushOptions)
-}
 
-func (g *Git) CheckoutHash(hash string) error {
-	worktree, err := g.repo.Worktree()
+	start := time.Now()
+	err = origin.Push(&pushOptions)
+	observeDuration("push", start)
 	if err != nil {
 		return err
 	}
 
-	err = worktree.Checkout(&gogit.CheckoutOptions{
-		Hash:  plumbing.NewHash(hash),
-		Force: true,
+	// Fetches don't prune, the remote-tracking branches would keep branches under the same names from being fetched
+	for _, branch := range branches {
+		err := gitRepo.repo.Storer.RemoveReference(plumbing.NewRemoteReferenceName(Origin, branch))
+		if err != nil {
+			return err
+		}
+	}
+	return gitRepo.removeEmptyRefDirs(path.Join("refs/remotes", Origin))
+}
+
+// ReadFileAt returns the contents of a file as it is in a commit, reading it from the object store
+func (g *Git) ReadFileAt(sha, file string) ([]byte, error) {
+	commit, err := g.repo.CommitObject(plumbing.NewHash(sha))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := commit.File(file)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := f.Contents()
+	return []byte(contents), err
+}
+
+// ResolveTag returns the sha of the commit a tag points to
+func (g *Git) ResolveTag(tag string) (string, error) {
+	hash, err := g.tagCommit(plumbing.NewTagReferenceName(tag))
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+func (g *Git) tagCommit(name plumbing.ReferenceName) (plumbing.Hash, error) {
+	ref, err := g.repo.Reference(name, true)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	// Annotated tags point to a tag object, lightweight tags point directly to the commit
+	tagObj, err := g.repo.TagObject(ref.Hash())
+	if err == nil {
+		commit, err := tagObj.Commit()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return commit.Hash, nil
+	} else if err != plumbing.ErrObjectNotFound {
+		return plumbing.ZeroHash, err
+	}
+
+	return ref.Hash(), nil
+}
+
+// CommitsSinceTag finds the most recent tag reachable from the given tag, and returns its name along with the
+// shas of the commits in the first parent chain of the given tag which aren't reachable from the previous one.
+// Those are the merge commits and the squashed or rebased commits of PRs, not the commits of merged branches.
+// If there is no previous tag, an empty name and no commits are returned.
+func (g *Git) CommitsSinceTag(tag string) (string, []string, error) {
+	tagHash, err := g.tagCommit(plumbing.NewTagReferenceName(tag))
+	if err != nil {
+		return "", nil, err
+	}
+
+	taggedCommits, err := g.taggedCommits()
+	if err != nil {
+		return "", nil, err
+	}
+
+	commits, err := g.repo.Log(&gogit.LogOptions{From: tagHash, Order: gogit.LogOrderCommitterTime})
+	if err != nil {
+		return "", nil, err
+	}
+
+	previousTag := ""
+	var previousHash plumbing.Hash
+	err = commits.ForEach(func(commit *object.Commit) error {
+		if commit.Hash == tagHash {
+			return nil
+		}
+		if name, ok := taggedCommits[commit.Hash]; ok {
+			previousTag = name
+			previousHash = commit.Hash
+			return storer.ErrStop
+		}
+		return nil
 	})
+	if err != nil || previousTag == "" {
+		return "", nil, err
+	}
+
+	released, err := g.reachableCommits(previousHash)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
-	return nil
+	commit, err := g.repo.CommitObject(tagHash)
+	if err != nil {
+		return "", nil, err
+	}
+
+	shas := []string{}
+	for !released[commit.Hash] {
+		shas = append(shas, commit.Hash.String())
+		if commit.NumParents() == 0 {
+			break
+		}
+		if commit, err = commit.Parent(0); err != nil {
+			return "", nil, err
+		}
+	}
+	return previousTag, shas, nil
 }
 
-func (g *Git) ReadFile(file string) ([]byte, error) {
-	filename := path.Join(dirName(g.name), file)
-	return os.ReadFile(filename)
+func (g *Git) taggedCommits() (map[plumbing.Hash]string, error) {
+	tags, err := g.repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	tagged := make(map[plumbing.Hash]string)
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		hash, err := g.tagCommit(ref.Name())
+		if err != nil {
+			klog.Warningf("Ignoring tag %s: %s", ref.Name().Short(), err)
+			return nil
+		}
+		tagged[hash] = ref.Name().Short()
+		return nil
+	})
+	return tagged, err
+}
+
+func (g *Git) reachableCommits(from plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	commits, err := g.repo.Log(&gogit.LogOptions{From: from})
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := make(map[plumbing.Hash]bool)
+	err = commits.ForEach(func(commit *object.Commit) error {
+		reachable[commit.Hash] = true
+		return nil
+	})
+	return reachable, err
 }
@@ -0,0 +1,227 @@
+package git
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const waitTimeout = 10 * time.Second
+
+func TestNewDoesNotWaitForOtherRepositories(t *testing.T) {
+	// The https transport only needs a token, which the local test remotes ignore
+	t.Setenv("GITHUB_TOKEN", "test")
+	t.Setenv("GIT_TRANSPORT", "https")
+
+	// The slow repository's remote holds the clone until it's released
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	releaseOnce := func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}
+	defer releaseOnce()
+
+	slowName := testRepoName(t, "slow")
+	fastName := testRepoName(t, "fast")
+	fastRemote := newTestRemote(t)
+
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := New(slowName, "", server.URL+"/slow.git")
+		slowDone <- err
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(waitTimeout):
+		t.Fatalf("the clone of %s didn't start", slowName)
+	}
+
+	fastDone := make(chan error, 1)
+	go func() {
+		_, err := New(fastName, "", fastRemote)
+		fastDone <- err
+	}()
+
+	select {
+	case err := <-fastDone:
+		if err != nil {
+			t.Fatalf("New(%s) failed: %s", fastName, err)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatalf("New(%s) waited for the clone of %s", fastName, slowName)
+	}
+
+	select {
+	case <-slowDone:
+		t.Fatalf("New(%s) returned before its remote was released", slowName)
+	default:
+	}
+
+	releaseOnce()
+	select {
+	case err := <-slowDone:
+		if err == nil {
+			t.Fatalf("New(%s) succeeded with a remote failing the clone", slowName)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatalf("New(%s) didn't return once its remote was released", slowName)
+	}
+}
+
+func TestNewWaitsForTheSameRepository(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "test")
+	t.Setenv("GIT_TRANSPORT", "https")
+
+	name := testRepoName(t, "locked")
+	remote := newTestRemote(t)
+	git := getProject(name)
+
+	git.Lock()
+	done := make(chan error, 1)
+	go func() {
+		_, err := New(name, "", remote)
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		git.Unlock()
+		t.Fatalf("New(%s) didn't wait for the lock of the repository", name)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Other repositories don't wait meanwhile
+	other := testRepoName(t, "other")
+	if _, err := New(other, "", newTestRemote(t)); err != nil {
+		git.Unlock()
+		t.Fatalf("New(%s) failed: %s", other, err)
+	}
+
+	git.Unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("New(%s) failed: %s", name, err)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatalf("New(%s) didn't return once the repository was unlocked", name)
+	}
+}
+
+// testRepoName returns a unique repository name, whose clone and project are removed once the test is done
+func testRepoName(t *testing.T, suffix string) string {
+	name := fmt.Sprintf("submariner-bot-test/%d-%s", time.Now().UnixNano(), suffix)
+	t.Cleanup(func() {
+		projectsLock.Lock()
+		delete(projects, name)
+		projectsLock.Unlock()
+		os.RemoveAll(dirName(name))
+	})
+	return name
+}
+
+// newTestRemote creates a repository with a commit, returning its path to use as a remote
+func newTestRemote(t *testing.T) string {
+	dir := t.TempDir()
+	repo, err := gogit.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("test\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add("README.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	signature := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	if _, err := worktree.Commit("Initial commit", &gogit.CommitOptions{Author: signature}); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestClosedBranchesAreKeptOnOrigin(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "test")
+	t.Setenv("GIT_TRANSPORT", "https")
+
+	git, err := New(testRepoName(t, "closed"), "", newTestRemote(t))
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+
+	branches, err := git.GetBranches()
+	if err != nil {
+		t.Fatalf("GetBranches failed: %s", err)
+	}
+
+	closed := Branches{}
+	for _, branch := range []string{"z_pr1/user/branch/1", "z_pr1/user/branch/2"} {
+		if err := git.CreateBranch(branch, branches["master"].String()); err != nil {
+			t.Fatalf("CreateBranch(%s) failed: %s", branch, err)
+		}
+		if err := git.Push(branch); err != nil {
+			t.Fatalf("Push(%s) failed: %s", branch, err)
+		}
+		closed[branch] = branches["master"]
+	}
+
+	if err := git.ArchiveBranches(1, closed); err != nil {
+		t.Fatalf("ArchiveBranches failed: %s", err)
+	}
+	if err := git.DeleteRemoteBranches([]string{"z_pr1/user/branch/1", "z_pr1/user/branch/2"}); err != nil {
+		t.Fatalf("DeleteRemoteBranches failed: %s", err)
+	}
+
+	fetched, err := git.FetchClosedBranches(1)
+	if err != nil {
+		t.Fatalf("FetchClosedBranches failed: %s", err)
+	}
+	if !reflect.DeepEqual(fetched, closed) {
+		t.Fatalf("FetchClosedBranches returned %v, expected %v", fetched, closed)
+	}
+
+	if fetched, err := git.FetchClosedBranches(2); err != nil || len(fetched) > 0 {
+		t.Fatalf("FetchClosedBranches for a PR without closed branches returned %v, %v", fetched, err)
+	}
+
+	if err := git.DeleteClosedBranches(1, []string{"z_pr1/user/branch/1"}); err != nil {
+		t.Fatalf("DeleteClosedBranches failed: %s", err)
+	}
+
+	fetched, err = git.FetchClosedBranches(1)
+	if err != nil {
+		t.Fatalf("FetchClosedBranches failed: %s", err)
+	}
+	if expected := (Branches{"z_pr1/user/branch/2": branches["master"]}); !reflect.DeepEqual(fetched, expected) {
+		t.Fatalf("FetchClosedBranches returned %v once a branch was restored, expected %v", fetched, expected)
+	}
+}
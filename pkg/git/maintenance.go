@@ -0,0 +1,160 @@
+package git
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"k8s.io/klog"
+)
+
+// minIdleBeforeRemoval is how long a clone must be unused before it can be removed to stay within the disk budget
+const minIdleBeforeRemoval = time.Hour
+
+// Maintain cleans up and repacks the objects of every repository, then removes the least recently used clones
+// while they take more than diskBudget bytes, if diskBudget is positive. Removed clones are cloned again when needed.
+func Maintain(diskBudget int64) {
+	projectsLock.Lock()
+	gits := make([]*Git, 0, len(projects))
+	for _, git := range projects {
+		gits = append(gits, git)
+	}
+	projectsLock.Unlock()
+
+	sizes := make(map[*Git]int64)
+	lastUsed := make(map[*Git]time.Time)
+	var total int64
+	for _, git := range gits {
+		git.Lock()
+		lastUsed[git] = git.lastUsed
+		if git.repo != nil {
+			if err := git.gc(); err != nil {
+				klog.Errorf("Error cleaning up repo %s: %s", git.name, err)
+			}
+
+			size, err := dirSize(dirName(git.name))
+			if err != nil {
+				klog.Errorf("Error getting the size of repo %s: %s", git.name, err)
+			}
+			sizes[git] = size
+			total += size
+		}
+		git.Unlock()
+	}
+	klog.Infof("Cloned repos take %d bytes after cleaning up", total)
+
+	if diskBudget <= 0 || total <= diskBudget {
+		return
+	}
+
+	sort.Slice(gits, func(i, j int) bool {
+		return lastUsed[gits[i]].Before(lastUsed[gits[j]])
+	})
+
+	for _, git := range gits {
+		if total <= diskBudget {
+			return
+		}
+
+		if size, ok := sizes[git]; ok && git.remove() {
+			total -= size
+		}
+	}
+
+	if total > diskBudget {
+		klog.Warningf("Cloned repos take %d bytes, over the budget of %d bytes, but the rest are in use", total, diskBudget)
+	}
+}
+
+// gc removes the local branches, which are only used to push, the fetched PR heads, and the unreachable objects,
+// packing the rest
+func (git *Git) gc() error {
+	head, err := git.repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return err
+	}
+
+	refs, err := git.repo.References()
+	if err != nil {
+		return err
+	}
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Name() == head.Target() || !(ref.Name().IsBranch() || strings.HasPrefix(ref.Name().String(), "refs/pull/")) {
+			return nil
+		}
+		return git.repo.Storer.RemoveReference(ref.Name())
+	})
+	if err != nil {
+		return err
+	}
+
+	// Nothing else uses the repository while it's locked, so there's no need to spare recent objects
+	if err := git.repo.Prune(gogit.PruneOptions{Handler: git.repo.DeleteObject}); err != nil {
+		return err
+	}
+
+	if err := git.repo.RepackObjects(&gogit.RepackConfig{}); err != nil {
+		return err
+	}
+
+	// The remaining loose objects are reachable, so they're in the new pack
+	if loose, ok := git.repo.Storer.(storer.LooseObjectStorer); ok {
+		if err := loose.ForEachObjectHash(loose.DeleteLooseObject); err != nil {
+			return err
+		}
+	}
+
+	// The storage caches the packs it found when it was opened
+	repo, err := gogit.PlainOpen(dirName(git.name))
+	if err != nil {
+		return err
+	}
+	git.repo = repo
+	return nil
+}
+
+// remove deletes the clone of a repository unless it was used recently, returning whether it was removed
+func (git *Git) remove() bool {
+	git.Lock()
+	defer git.Unlock()
+
+	if git.repo == nil || time.Since(git.lastUsed) < minIdleBeforeRemoval {
+		return false
+	}
+
+	// The Git stays in projects, so whoever waits for its lock in New clones it again
+	git.repo = nil
+	if err := os.RemoveAll(dirName(git.name)); err != nil {
+		klog.Errorf("Error removing the clone of %s: %s", git.name, err)
+		return false
+	}
+
+	klog.Infof("Removed the clone of %s, last used at %s", git.name, git.lastUsed)
+	return true
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
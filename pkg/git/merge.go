@@ -0,0 +1,136 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// hunk replaces the base lines [start, end) with lines
+type hunk struct {
+	start int
+	end   int
+	lines []string
+}
+
+// merge3 merges the changes from base to ours and from base to theirs line by line, like git does. It fails when
+// both sides change the same or adjacent lines differently, or when the contents are binary.
+func merge3(base, ours, theirs []byte) ([]byte, bool) {
+	if isBinary(base) || isBinary(ours) || isBinary(theirs) {
+		return nil, false
+	}
+
+	baseLines := splitLines(string(base))
+	ourHunks := hunks(string(base), string(ours))
+	theirHunks := hunks(string(base), string(theirs))
+
+	var merged strings.Builder
+	pos := 0
+	for len(ourHunks) > 0 || len(theirHunks) > 0 {
+		// Start a cluster with the first hunk, and add the hunks of either side which touch it until none is left
+		var ourCluster, theirCluster []hunk
+		var lo, hi int
+		if len(theirHunks) == 0 || (len(ourHunks) > 0 && ourHunks[0].start <= theirHunks[0].start) {
+			lo, hi = ourHunks[0].start, ourHunks[0].end
+			ourCluster, ourHunks = append(ourCluster, ourHunks[0]), ourHunks[1:]
+		} else {
+			lo, hi = theirHunks[0].start, theirHunks[0].end
+			theirCluster, theirHunks = append(theirCluster, theirHunks[0]), theirHunks[1:]
+		}
+
+		for grown := true; grown; {
+			grown = false
+			if len(ourHunks) > 0 && ourHunks[0].start <= hi {
+				hi = max(hi, ourHunks[0].end)
+				ourCluster, ourHunks = append(ourCluster, ourHunks[0]), ourHunks[1:]
+				grown = true
+			}
+			if len(theirHunks) > 0 && theirHunks[0].start <= hi {
+				hi = max(hi, theirHunks[0].end)
+				theirCluster, theirHunks = append(theirCluster, theirHunks[0]), theirHunks[1:]
+				grown = true
+			}
+		}
+
+		merged.WriteString(strings.Join(baseLines[pos:lo], ""))
+		pos = hi
+
+		switch {
+		case len(theirCluster) == 0:
+			merged.WriteString(apply(baseLines, lo, hi, ourCluster))
+		case len(ourCluster) == 0:
+			merged.WriteString(apply(baseLines, lo, hi, theirCluster))
+		default:
+			// Both sides changed this part, which is only fine if they made the same change
+			ourText, theirText := apply(baseLines, lo, hi, ourCluster), apply(baseLines, lo, hi, theirCluster)
+			if ourText != theirText {
+				return nil, false
+			}
+			merged.WriteString(ourText)
+		}
+	}
+	merged.WriteString(strings.Join(baseLines[pos:], ""))
+
+	return []byte(merged.String()), true
+}
+
+// hunks returns the changes from src to dst, ordered by their position in src
+func hunks(src, dst string) []hunk {
+	result := []hunk{}
+	line := 0
+	var current *hunk
+	for _, d := range diff.Do(src, dst) {
+		lines := splitLines(d.Text)
+		if d.Type == diffmatchpatch.DiffEqual {
+			if current != nil {
+				result = append(result, *current)
+				current = nil
+			}
+			line += len(lines)
+			continue
+		}
+
+		if current == nil {
+			current = &hunk{start: line, end: line}
+		}
+		if d.Type == diffmatchpatch.DiffDelete {
+			line += len(lines)
+			current.end = line
+		} else {
+			current.lines = append(current.lines, lines...)
+		}
+	}
+	if current != nil {
+		result = append(result, *current)
+	}
+	return result
+}
+
+// apply returns the base lines [lo, hi) with the hunks, which are within that range, applied
+func apply(baseLines []string, lo, hi int, hunks []hunk) string {
+	var sb strings.Builder
+	pos := lo
+	for _, h := range hunks {
+		sb.WriteString(strings.Join(baseLines[pos:h.start], ""))
+		sb.WriteString(strings.Join(h.lines, ""))
+		pos = h.end
+	}
+	sb.WriteString(strings.Join(baseLines[pos:hi], ""))
+	return sb.String()
+}
+
+// splitLines splits text in lines, keeping their line endings so joining them gives the text back
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// isBinary uses the same heuristic as git, content with a NUL byte in its first 8000 bytes is binary
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0
+}
@@ -0,0 +1,135 @@
+package git
+
+import "testing"
+
+func TestMerge3(t *testing.T) {
+	const base = "a\nb\nc\nd\ne\n"
+
+	tests := []struct {
+		name   string
+		base   string
+		ours   string
+		theirs string
+		merged string
+		ok     bool
+	}{
+		{
+			name:   "disjoint changes",
+			base:   base,
+			ours:   "a\nB\nc\nd\ne\n",
+			theirs: "a\nb\nc\nD\ne\n",
+			merged: "a\nB\nc\nD\ne\n",
+			ok:     true,
+		},
+		{
+			name:   "disjoint deletion and change",
+			base:   base,
+			ours:   "a\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\nE\n",
+			merged: "a\nc\nd\nE\n",
+			ok:     true,
+		},
+		{
+			name:   "one side unchanged",
+			base:   base,
+			ours:   base,
+			theirs: "x\na\nb\nC\nd\ne\n",
+			merged: "x\na\nb\nC\nd\ne\n",
+			ok:     true,
+		},
+		{
+			name:   "adjacent changes",
+			base:   base,
+			ours:   "a\nB\nc\nd\ne\n",
+			theirs: "a\nb\nC\nd\ne\n",
+		},
+		{
+			name:   "overlapping changes",
+			base:   base,
+			ours:   "a\nB\nC\nd\ne\n",
+			theirs: "a\nb\nc2\nd\ne\n",
+		},
+		{
+			name:   "identical changes on both sides",
+			base:   base,
+			ours:   "a\nB\nc\nD\ne\n",
+			theirs: "a\nB\nc\nd\ne\n",
+			merged: "a\nB\nc\nD\ne\n",
+			ok:     true,
+		},
+		{
+			name:   "different insertions at the same point",
+			base:   base,
+			ours:   "a\nb\nx\nc\nd\ne\n",
+			theirs: "a\nb\ny\nc\nd\ne\n",
+		},
+		{
+			name:   "identical insertions at the same point",
+			base:   base,
+			ours:   "a\nb\nx\nc\nd\ne\n",
+			theirs: "a\nb\nx\nc\nd\ne\n",
+			merged: "a\nb\nx\nc\nd\ne\n",
+			ok:     true,
+		},
+		{
+			name:   "insertions at both ends",
+			base:   base,
+			ours:   "x\na\nb\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\ne\ny\n",
+			merged: "x\na\nb\nc\nd\ne\ny\n",
+			ok:     true,
+		},
+		{
+			name:   "missing final newline kept",
+			base:   "a\nb\nc",
+			ours:   "A\nb\nc",
+			theirs: "a\nb\nC",
+			merged: "A\nb\nC",
+			ok:     true,
+		},
+		{
+			name:   "final newline added",
+			base:   "a\nb\nc",
+			ours:   "A\nb\nc",
+			theirs: "a\nb\nc\n",
+			merged: "A\nb\nc\n",
+			ok:     true,
+		},
+		{
+			name:   "final newline added and last line changed",
+			base:   "a\nb\nc",
+			ours:   "a\nb\nC",
+			theirs: "a\nb\nc\n",
+		},
+		{
+			name:   "empty base",
+			base:   "",
+			ours:   "a\n",
+			theirs: "b\n",
+		},
+		{
+			name:   "binary content",
+			base:   "a\x00b\n",
+			ours:   "a\x00B\n",
+			theirs: "A\x00b\n",
+		},
+		{
+			name:   "binary content on one side",
+			base:   base,
+			ours:   "a\nB\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\x00\ne\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged, ok := merge3([]byte(test.base), []byte(test.ours), []byte(test.theirs))
+			if ok != test.ok {
+				t.Fatalf("merge3 returned ok %t, expected %t (merged %q)", ok, test.ok, merged)
+			}
+			if ok && string(merged) != test.merged {
+				t.Fatalf("merge3 returned %q, expected %q", merged, test.merged)
+			}
+		})
+	}
+}
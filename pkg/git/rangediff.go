@@ -0,0 +1,196 @@
+package git
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// RangeDiff statuses, similar to the ones git range-diff uses
+const (
+	RangeDiffUnchanged = "="
+	RangeDiffModified  = "!"
+	RangeDiffAdded     = "+"
+	RangeDiffRemoved   = "-"
+)
+
+type RangeDiffEntry struct {
+	Status string
+	// OldSHA is empty for added commits, NewSHA is empty for removed commits
+	OldSHA string
+	NewSHA string
+	Title  string
+}
+
+// IsAncestor returns whether the ancestor commit is reachable from the descendant commit
+func (g *Git) IsAncestor(ancestor, descendant string) (bool, error) {
+	ancestorCommit, err := g.repo.CommitObject(plumbing.NewHash(ancestor))
+	if err != nil {
+		return false, err
+	}
+
+	descendantCommit, err := g.repo.CommitObject(plumbing.NewHash(descendant))
+	if err != nil {
+		return false, err
+	}
+
+	return ancestorCommit.IsAncestor(descendantCommit)
+}
+
+// RangeDiff compares the commits between base and oldHead with the ones between base and newHead, pairing them
+// by title and comparing their changes regardless of the commit they are based on
+func (g *Git) RangeDiff(base, oldHead, newHead string) ([]RangeDiffEntry, error) {
+	oldCommits, err := g.commitsInRange(base, oldHead)
+	if err != nil {
+		return nil, err
+	}
+
+	newCommits, err := g.commitsInRange(base, newHead)
+	if err != nil {
+		return nil, err
+	}
+
+	oldByTitle := make(map[string]*object.Commit)
+	for _, commit := range oldCommits {
+		oldByTitle[commitTitle(commit)] = commit
+	}
+
+	entries := []RangeDiffEntry{}
+	for _, commit := range newCommits {
+		title := commitTitle(commit)
+		entry := RangeDiffEntry{Status: RangeDiffAdded, NewSHA: commit.Hash.String(), Title: title}
+
+		if oldCommit, ok := oldByTitle[title]; ok {
+			delete(oldByTitle, title)
+			entry.OldSHA = oldCommit.Hash.String()
+
+			same, err := samePatch(oldCommit, commit)
+			if err != nil {
+				return nil, err
+			}
+
+			if same {
+				entry.Status = RangeDiffUnchanged
+			} else {
+				entry.Status = RangeDiffModified
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, commit := range oldCommits {
+		if _, ok := oldByTitle[commitTitle(commit)]; ok {
+			entries = append(entries, RangeDiffEntry{
+				Status: RangeDiffRemoved,
+				OldSHA: commit.Hash.String(),
+				Title:  commitTitle(commit),
+			})
+		}
+	}
+
+	return entries, nil
+}
+
+// CommitsInRange returns the shas of the commits reachable from head but not from base, oldest first
+func (g *Git) CommitsInRange(base, head string) ([]string, error) {
+	commits, err := g.commitsInRange(base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	shas := []string{}
+	for _, commit := range commits {
+		shas = append(shas, commit.Hash.String())
+	}
+	return shas, nil
+}
+
+// commitsInRange returns the commits reachable from head but not from base, oldest first
+func (g *Git) commitsInRange(base, head string) ([]*object.Commit, error) {
+	excluded, err := g.reachableCommits(plumbing.NewHash(base))
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := g.repo.Log(&gogit.LogOptions{From: plumbing.NewHash(head)})
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []*object.Commit{}
+	err = iter.ForEach(func(commit *object.Commit) error {
+		if !excluded[commit.Hash] {
+			commits = append([]*object.Commit{commit}, commits...)
+		}
+		return nil
+	})
+	return commits, err
+}
+
+func commitTitle(commit *object.Commit) string {
+	return strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0]
+}
+
+func samePatch(a, b *object.Commit) (bool, error) {
+	idA, err := patchID(a)
+	if err != nil {
+		return false, err
+	}
+
+	idB, err := patchID(b)
+	if err != nil {
+		return false, err
+	}
+
+	return idA == idB, nil
+}
+
+// patchID hashes the lines a commit adds and removes per file, so it doesn't depend on the context the
+// changes are applied to
+func patchID(commit *object.Commit) (string, error) {
+	if commit.NumParents() == 0 {
+		return commit.TreeHash.String(), nil
+	}
+
+	parent, err := commit.Parent(0)
+	if err != nil {
+		return "", err
+	}
+
+	patch, err := parent.Patch(commit)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha1.New()
+	for _, filePatch := range patch.FilePatches() {
+		from, to := filePatch.Files()
+		if from != nil {
+			fmt.Fprintf(hash, "--- %s\n", from.Path())
+		}
+		if to != nil {
+			fmt.Fprintf(hash, "+++ %s\n", to.Path())
+			if filePatch.IsBinary() {
+				fmt.Fprintf(hash, "binary %s\n", to.Hash())
+			}
+		}
+
+		for _, chunk := range filePatch.Chunks() {
+			switch chunk.Type() {
+			case diff.Add:
+				fmt.Fprintf(hash, "+%s", chunk.Content())
+			case diff.Delete:
+				fmt.Fprintf(hash, "-%s", chunk.Content())
+			case diff.Equal:
+			}
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"github.com/go-playground/webhooks/v6/github"
 
+	"github.com/submariner-io/submariner-bot/pkg/handler/issuecomment"
 	"github.com/submariner-io/submariner-bot/pkg/handler/pullrequest"
 )
 
@@ -11,6 +12,7 @@ func EventsToHandle() []github.Event {
 		github.ReleaseEvent,
 		github.PullRequestEvent,
 		github.PullRequestReviewEvent,
+		github.IssueCommentEvent,
 	}
 }
 
@@ -21,6 +23,43 @@ func Handle(payload interface{}) error {
 		return pullrequest.Handle(payload)
 	case github.PullRequestReviewPayload:
 		return handlePullRequestReview(payload)
+	case github.ReleasePayload:
+		return handleRelease(payload)
+	case github.IssueCommentPayload:
+		return issuecomment.Handle(payload)
 	}
 	return nil
 }
+
+// RepositoryOf returns the full name of the repository a payload refers to, events for the same
+// repository must be handled sequentially
+func RepositoryOf(payload interface{}) string {
+	switch payload := payload.(type) {
+
+	case github.PullRequestPayload:
+		return payload.Repository.FullName
+	case github.PullRequestReviewPayload:
+		return payload.Repository.FullName
+	case github.ReleasePayload:
+		return payload.Repository.FullName
+	case github.IssueCommentPayload:
+		return payload.Repository.FullName
+	}
+	return ""
+}
+
+// ActionOf returns the action which triggered the event of a payload
+func ActionOf(payload interface{}) string {
+	switch payload := payload.(type) {
+
+	case github.PullRequestPayload:
+		return payload.Action
+	case github.PullRequestReviewPayload:
+		return payload.Action
+	case github.ReleasePayload:
+		return payload.Action
+	case github.IssueCommentPayload:
+		return payload.Action
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+package issuecomment
+
+import (
+	"fmt"
+
+	"github.com/submariner-io/submariner-bot/pkg/cherrypick"
+)
+
+func init() {
+	Register("cherry-pick", &Command{Permission: PermissionWrite, Run: cherryPick})
+}
+
+// cherryPick opens backport PRs to the given branches, right away if the PR is merged or once it is otherwise
+func cherryPick(ctx *Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: /cherry-pick <branch> [<branch>...]")
+	}
+
+	if !ctx.PR.GetMerged() {
+		for _, target := range args {
+			if err := ctx.GH.AddLabel(ctx.PRNum, cherrypick.LabelPrefix+target); err != nil {
+				return err
+			}
+		}
+		ctx.GH.CommentOnPR(ctx.PRNum, "@%s I will cherry-pick this PR to %v once it's merged", ctx.User, args)
+		return nil
+	}
+
+	pr := &cherrypick.PR{
+		Num:      ctx.PRNum,
+		Title:    ctx.PR.GetTitle(),
+		MergeSHA: ctx.PR.GetMergeCommitSHA(),
+	}
+	for _, target := range args {
+		if err := cherrypick.Run(ctx.GH, ctx.Git, pr, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package issuecomment
+
+import (
+	"strings"
+
+	"github.com/go-playground/webhooks/v6/github"
+	gogithub "github.com/google/go-github/v28/github"
+
+	"github.com/submariner-io/submariner-bot/pkg/config/repoconfig"
+	"github.com/submariner-io/submariner-bot/pkg/ghclient"
+	"github.com/submariner-io/submariner-bot/pkg/git"
+)
+
+// Permission levels on a repository as returned by GitHub, from the lowest to the highest
+const (
+	PermissionNone  = "none"
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+	PermissionAdmin = "admin"
+)
+
+var permissionRanks = map[string]int{
+	PermissionNone:  0,
+	PermissionRead:  1,
+	PermissionWrite: 2,
+	PermissionAdmin: 3,
+}
+
+// Context is what commands get to work on the PR they were invoked on
+type Context struct {
+	GH      ghclient.GH
+	Git     *git.Git
+	Config  *repoconfig.BotConfig
+	Payload *github.IssueCommentPayload
+	PR      *gogithub.PullRequest
+	PRNum   int
+	User    string
+}
+
+type Command struct {
+	// Permission is the minimum permission level on the repository a user needs to run the command
+	Permission string
+	Run        func(ctx *Context, args []string) error
+}
+
+var registry = make(map[string]*Command)
+
+// Register adds a command to the ones which can be enabled in the bot config, name is used without the leading slash
+func Register(name string, cmd *Command) {
+	registry[name] = cmd
+}
+
+type invocation struct {
+	name string
+	args []string
+}
+
+// parse finds the commands in a comment, one per line as "/name arg1 arg2..."
+func parse(body string) []invocation {
+	invocations := []invocation{}
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") || len(fields[0]) == 1 {
+			continue
+		}
+
+		name := strings.ToLower(fields[0][1:])
+		if _, ok := registry[name]; !ok {
+			// Could be meant for another bot
+			continue
+		}
+		invocations = append(invocations, invocation{name: name, args: fields[1:]})
+	}
+	return invocations
+}
+
+func hasPermission(level, required string) bool {
+	return permissionRanks[level] >= permissionRanks[required]
+}
@@ -0,0 +1,106 @@
+package issuecomment
+
+import (
+	"fmt"
+
+	"github.com/go-playground/webhooks/v6/github"
+	"k8s.io/klog"
+
+	"github.com/submariner-io/submariner-bot/pkg/config/repoconfig"
+	"github.com/submariner-io/submariner-bot/pkg/ghclient"
+	"github.com/submariner-io/submariner-bot/pkg/git"
+)
+
+func Handle(ic github.IssueCommentPayload) error {
+	// Comments on plain issues have no pull_request, and we don't react to bots (including ourselves)
+	if ic.Action != "created" || ic.Issue.PullRequest == nil || ic.Comment.User.Type == "Bot" {
+		return nil
+	}
+
+	invocations := parse(ic.Comment.Body)
+	if len(invocations) == 0 {
+		return nil
+	}
+
+	prNum := int(ic.Issue.Number)
+	user := ic.Comment.User.Login
+	gh, err := ghclient.New(ic.Repository.Owner.Login, ic.Repository.Name)
+	if err != nil {
+		klog.Errorf("creating github client: %s", err)
+		return err
+	}
+
+	config, err := repoconfig.ReadDefaultBranch(gh)
+	if err != nil {
+		klog.Infof("Error reading bot config: %s", err)
+		return err
+	}
+
+	// Commands which aren't enabled are ignored silently, they could be meant for another bot such as Prow
+	invocations = enabled(config, invocations)
+	if len(invocations) == 0 {
+		return nil
+	}
+
+	klog.Infof("handling %d commands from %s on %s PR #%d", len(invocations), user, ic.Repository.FullName, prNum)
+	pr, err := gh.GetPR(prNum)
+	if err != nil {
+		klog.Errorf("getting PR #%d: %s", prNum, err)
+		return err
+	}
+
+	gitRepo, err := git.New(pr.GetBase().GetRepo().GetFullName(), pr.GetBase().GetRepo().GetSSHURL(),
+		pr.GetBase().GetRepo().GetCloneURL())
+	if err != nil {
+		klog.Errorf("creating git object: %s", err)
+		return err
+	}
+
+	gitRepo.Lock()
+	defer gitRepo.Unlock()
+
+	level, err := gh.GetPermissionLevel(user)
+	if err != nil {
+		klog.Errorf("getting permission level of %s: %s", user, err)
+		return err
+	}
+
+	ctx := &Context{
+		GH:      gh,
+		Git:     gitRepo,
+		Config:  config,
+		Payload: &ic,
+		PR:      pr,
+		PRNum:   prNum,
+		User:    user,
+	}
+
+	for _, inv := range invocations {
+		if err := run(ctx, level, inv); err != nil {
+			klog.Errorf("running /%s on PR #%d: %s", inv.name, prNum, err)
+			gh.CommentOnPR(prNum, "@%s I had an issue running `/%s`: %s", user, inv.name, err)
+		}
+	}
+
+	return nil
+}
+
+func enabled(config *repoconfig.BotConfig, invocations []invocation) []invocation {
+	kept := []invocation{}
+	for _, inv := range invocations {
+		if config.CommandEnabled(inv.name) {
+			kept = append(kept, inv)
+		}
+	}
+	return kept
+}
+
+func run(ctx *Context, level string, inv invocation) error {
+	cmd := registry[inv.name]
+	if !hasPermission(level, cmd.Permission) {
+		return fmt.Errorf("it needs %s permission on the repository, and you have %s", cmd.Permission, level)
+	}
+
+	klog.Infof("running /%s %v for %s on PR #%d", inv.name, inv.args, ctx.User, ctx.PRNum)
+	return cmd.Run(ctx, inv.args)
+}
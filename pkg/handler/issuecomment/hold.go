@@ -0,0 +1,15 @@
+package issuecomment
+
+const holdLabel = "do-not-merge/hold"
+
+func init() {
+	Register("hold", &Command{Permission: PermissionWrite, Run: hold})
+}
+
+// hold labels the PR so it isn't merged, "/hold cancel" removes the label
+func hold(ctx *Context, args []string) error {
+	if len(args) > 0 && args[0] == "cancel" {
+		return ctx.GH.RemoveLabel(ctx.PRNum, holdLabel)
+	}
+	return ctx.GH.AddLabel(ctx.PRNum, holdLabel)
+}
@@ -0,0 +1,36 @@
+package issuecomment
+
+import (
+	"fmt"
+)
+
+func init() {
+	Register("label", &Command{Permission: PermissionWrite, Run: label})
+	Register("remove-label", &Command{Permission: PermissionWrite, Run: removeLabel})
+}
+
+func label(ctx *Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: /label <label> [<label>...]")
+	}
+
+	for _, name := range args {
+		if err := ctx.GH.AddLabel(ctx.PRNum, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeLabel(ctx *Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: /remove-label <label> [<label>...]")
+	}
+
+	for _, name := range args {
+		if err := ctx.GH.RemoveLabel(ctx.PRNum, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package issuecomment
+
+func init() {
+	Register("retest", &Command{Permission: PermissionRead, Run: retest})
+}
+
+// retest re-runs the failed jobs of the workflows for the PR's head commit
+func retest(ctx *Context, args []string) error {
+	rerun, err := ctx.GH.RerunFailedWorkflows(ctx.PR.GetHead().GetSHA())
+	if err != nil {
+		return err
+	}
+
+	if rerun == 0 {
+		ctx.GH.CommentOnPR(ctx.PRNum, "@%s there are no failed workflows to re-run", ctx.User)
+		return nil
+	}
+
+	ctx.GH.CommentOnPR(ctx.PRNum, "@%s re-running the failed jobs of %d workflows", ctx.User, rerun)
+	return nil
+}
@@ -0,0 +1,101 @@
+package pullrequest
+
+import (
+	"fmt"
+	"strings"
+
+	gogithub "github.com/google/go-github/v28/github"
+	"k8s.io/klog"
+
+	"github.com/submariner-io/submariner-bot/pkg/ghclient"
+	"github.com/submariner-io/submariner-bot/pkg/git"
+)
+
+// CollectOrphanedBranches deletes the z_pr branches left behind by missed close events, which are the ones of PRs
+// which aren't open anymore, or which don't match the head of their PR. Branches other open PRs are based on are
+// kept, as closing the PR would. In dry-run mode the branches are only reported.
+func CollectOrphanedBranches(repo string, dryRun bool) error {
+	owner, name, found := strings.Cut(repo, "/")
+	if !found {
+		return fmt.Errorf("%q isn't a full repository name", repo)
+	}
+
+	gh, err := ghclient.New(owner, name)
+	if err != nil {
+		return err
+	}
+
+	sshURL, cloneURL := git.GitHubURLs(repo)
+	gitRepo, err := git.New(repo, sshURL, cloneURL)
+	if err != nil {
+		return err
+	}
+
+	gitRepo.Lock()
+	defer gitRepo.Unlock()
+
+	openPRs, err := gh.ListOpenPRs()
+	if err != nil {
+		return err
+	}
+
+	branches, err := gitRepo.GetBranches()
+	if err != nil {
+		return err
+	}
+
+	open := make(map[int]*gogithub.PullRequest)
+	bases := make(map[string]bool)
+	for _, pr := range openPRs {
+		open[pr.GetNumber()] = pr
+		bases[pr.GetBase().GetRef()] = true
+	}
+
+	orphaned := []string{}
+	kept := []string{}
+	// closedOrphaned holds the orphaned branches of the PRs which aren't open anymore
+	closedOrphaned := make(map[int][]string)
+	for prNum, prBranches := range prBranches(branches) {
+		for _, branch := range prBranches {
+			switch {
+			case bases[branch]:
+				kept = append(kept, branch)
+			case open[prNum] == nil:
+				closedOrphaned[prNum] = append(closedOrphaned[prNum], branch)
+				orphaned = append(orphaned, branch)
+			case !matchesHead(open[prNum], branch):
+				orphaned = append(orphaned, branch)
+			}
+		}
+	}
+
+	klog.Infof("Found %d orphaned z_pr branches in %s: %v, keeping %d with dependent PRs: %v",
+		len(orphaned), repo, orphaned, len(kept), kept)
+
+	if dryRun || len(orphaned) == 0 {
+		return nil
+	}
+
+	// As when the close event is handled, the branches of PRs closed without being merged are kept to be restored
+	for prNum, prOrphaned := range closedOrphaned {
+		pr, err := gh.GetPR(prNum)
+		if err != nil {
+			klog.Errorf("Error getting PR #%d to keep its closed branches: %s", prNum, err)
+			return err
+		}
+
+		if !pr.GetMerged() {
+			if err := recordClosedPR(gitRepo, prNum, branches, prOrphaned); err != nil {
+				return err
+			}
+		}
+	}
+
+	return deleteBranches(gitRepo, orphaned)
+}
+
+// matchesHead returns whether a z_pr branch was created for the current head of a PR
+func matchesHead(pr *gogithub.PullRequest, branch string) bool {
+	prBranch := fmt.Sprintf("z_pr%d/%s/%s", pr.GetNumber(), pr.GetHead().GetUser().GetLogin(), pr.GetHead().GetRef())
+	return branch == prBranch || strings.HasPrefix(branch, prBranch+"/")
+}
@@ -2,19 +2,26 @@ package pullrequest
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/go-playground/webhooks/v6/github"
 	"k8s.io/klog"
 
+	"github.com/submariner-io/submariner-bot/pkg/cherrypick"
 	"github.com/submariner-io/submariner-bot/pkg/config/repoconfig"
 	"github.com/submariner-io/submariner-bot/pkg/ghclient"
 	"github.com/submariner-io/submariner-bot/pkg/git"
 )
 
-// NOTE: this has been disabled in code for just in case we think it'd valuable to enable later
-const enableVersionBranches = false
+// Kinds of the sticky comments, the branches one tracks the z_pr branches of the PR, the base one tracks
+// changes done to the base of a dependent PR, and the approval one tracks the approved label being reset
+const (
+	branchesCommentKind = "branches"
+	baseCommentKind     = "base"
+	approvalCommentKind = "approval"
+)
 
 func Handle(pr github.PullRequestPayload) error {
 	logPullRequestInfo(&pr)
@@ -24,7 +31,8 @@ func Handle(pr github.PullRequestPayload) error {
 		return err
 	}
 
-	gitRepo, err := git.New(pr.PullRequest.Base.Repo.FullName, pr.PullRequest.Base.Repo.SSHURL)
+	gitRepo, err := git.New(pr.PullRequest.Base.Repo.FullName, pr.PullRequest.Base.Repo.SSHURL,
+		pr.PullRequest.Base.Repo.CloneURL)
 	if err != nil {
 		klog.Errorf("creating git object: %s", err)
 		return err
@@ -33,18 +41,35 @@ func Handle(pr github.PullRequestPayload) error {
 	gitRepo.Lock()
 	defer gitRepo.Unlock()
 
+	prNum := int(pr.Number)
 	switch pr.Action {
 	case "opened":
-		return openOrSync(gitRepo, &pr, gh)
+		err = openOrSync(gitRepo, &pr, gh)
+		updateStackComments(gitRepo, gh, []int{prNum})
+		return err
 	case "synchronize":
+		resetApproval(gitRepo, &pr, gh)
 		return openOrSync(gitRepo, &pr, gh)
+	case "edited":
+		// The base may have changed, moving the PR to another stack
+		updateStackComments(gitRepo, gh, []int{prNum})
 	case "closed":
-		// TODO: if closed and pr.PullRequest.Merged == true, look for existing PR's pointing to the
-		// merged version and change the base to "master" or pr.PullRequest.Base.Ref
-		return closeBranches(gitRepo, &pr, gh)
+		if pr.PullRequest.Merged {
+			cherryPick(gitRepo, &pr, gh)
+		}
+		members := stackMembers(gitRepo, gh, prNum)
+		err = closeBranches(gitRepo, &pr, gh)
+		updateStackComments(gitRepo, gh, members)
+		pruneContributorRemote(gitRepo, gh, pr.PullRequest.User.Login)
+		return err
 	case "reopened":
-		// TODO: when re-opened it would be ideal to recover the previous branches, how?
-		return openOrSync(gitRepo, &pr, gh)
+		// The versions are restored first, so the head is compared with the latest one
+		if err := restoreBranches(gitRepo, &pr, gh); err != nil {
+			return err
+		}
+		err = openOrSync(gitRepo, &pr, gh)
+		updateStackComments(gitRepo, gh, []int{prNum})
+		return err
 	}
 
 	return nil
@@ -86,8 +111,7 @@ func openOrSync(gitRepo *git.Git, pr *github.PullRequestPayload, gh ghclient.GH)
 		return nil
 	}
 
-	err = gitRepo.EnsureAndFetch(pr.PullRequest.User.Login, pr.PullRequest.Head.Repo.SSHURL)
-	if err != nil {
+	if err := fetchHead(gitRepo, pr); err != nil {
 		klog.Errorf("git remote setup failed: %s", err)
 		return err
 	}
@@ -98,7 +122,7 @@ func openOrSync(gitRepo *git.Git, pr *github.PullRequestPayload, gh ghclient.GH)
 		return nil
 	}
 
-	versionBranch := getVersionBranch(pr, branches)
+	versionBranch := switchLayout(gitRepo, pr, gh, branches, getVersionBranch(gitRepo, pr, branches, config))
 
 	err = gitRepo.CreateBranch(versionBranch, pr.PullRequest.Head.Sha)
 	if err != nil {
@@ -108,6 +132,9 @@ func openOrSync(gitRepo *git.Git, pr *github.PullRequestPayload, gh ghclient.GH)
 	var infoMsg string
 	if branches[versionBranch] == nil {
 		infoMsg = fmt.Sprintf("Created branch: %s %s", versionBranch, readyToReviewMsg)
+		if previous, _ := latestVersionBranch(pr, branches); previous != "" {
+			infoMsg += rangeDiffMsg(gitRepo, pr, previous, branches[previous].String())
+		}
 	}
 
 	klog.Infof(infoMsg)
@@ -118,64 +145,529 @@ func openOrSync(gitRepo *git.Git, pr *github.PullRequestPayload, gh ghclient.GH)
 		return err
 	}
 
-	if infoMsg != "" {
-		gh.CommentOnPR(prNum, infoMsg)
+	klog.Infof("Pushed branch: %s", versionBranch)
+
+	if config != nil && config.BranchVersioning != nil {
+		pruned := pruneVersionBranches(gitRepo, pr, gh, branches, versionBranch, *config.BranchVersioning.Retention)
+		if len(pruned) > 0 {
+			infoMsg += fmt.Sprintf("\nPruned old versions: %s", pruned)
+		}
 	}
 
-	klog.Infof("Pushed branch: %s", versionBranch)
+	if infoMsg != "" {
+		gh.StickyComment(prNum, branchesCommentKind, "%s", infoMsg)
+	}
 	return err
 }
 
-func getVersionBranch(pr *github.PullRequestPayload, branches git.Branches) string {
-	if enableVersionBranches {
-		return getNextVersionBranch(pr, branches)
-	} else {
-		return versionedBranch(pr)
+// fetchHead fetches the head commit of a PR from origin, falling back to fetching the contributor's fork
+func fetchHead(gitRepo *git.Git, pr *github.PullRequestPayload) error {
+	err := gitRepo.FetchPullHead(int(pr.Number), pr.PullRequest.Head.Sha)
+	if err == nil {
+		return nil
 	}
+
+	klog.Warningf("Error fetching the head of PR %d from %s, fetching the fork instead: %s", pr.Number, git.Origin, err)
+	return gitRepo.EnsureAndFetch(pr.PullRequest.User.Login,
+		gitRepo.RemoteURL(pr.PullRequest.Head.Repo.SSHURL, pr.PullRequest.Head.Repo.CloneURL))
 }
 
-func getNextVersionBranch(pr *github.PullRequestPayload, branches git.Branches) string {
-	existing := filterVersionBranches(pr, branches)
+// resetApproval removes the approved label when new commits are pushed, if the bot config asks for it
+func resetApproval(gitRepo *git.Git, pr *github.PullRequestPayload, gh ghclient.GH) {
+	config, err := repoconfig.Read(gitRepo, pr.PullRequest.Base.Sha)
+	if err != nil {
+		klog.Infof("Error reading bot config: %s", err)
+		return
+	}
 
-	num := 0
-	for _, branch := range existing {
-		parts := strings.Split(branch, "/")
-		if numBr, _ := strconv.Atoi(parts[len(parts)-1]); numBr > num {
-			num = numBr
+	if !config.ResetsApprovalOnPush(pr.PullRequest.User.Login) {
+		return
+	}
+
+	label := *config.LabelApproved.Label
+	labeled := false
+	for _, l := range pr.PullRequest.Labels {
+		if l.Name == label {
+			labeled = true
 		}
 	}
+
+	if !labeled {
+		return
+	}
+
+	prNum := int(pr.Number)
+	klog.Infof("removing label %s from PR #%d after new commits were pushed", label, prNum)
+	if err := gh.RemoveLabel(prNum, label); err != nil {
+		klog.Errorf("error while removing label %s from PR #%d: %s", label, prNum, err)
+		return
+	}
+
+	gh.StickyComment(prNum, approvalCommentKind, "New commits were pushed after this PR was approved, so I removed the %q label. "+
+		"I will add it again once the latest commit has %d approvals.", label, *config.LabelApproved.Approvals)
+}
+
+func getVersionBranch(gitRepo *git.Git, pr *github.PullRequestPayload, branches git.Branches,
+	config *repoconfig.BotConfig,
+) string {
+	if config == nil || config.BranchVersioning == nil {
+		return versionedBranch(pr)
+	}
+
+	latest, num := latestVersionBranch(pr, branches)
+	if latest == "" {
+		return fmt.Sprintf(versionedBranchFmt(pr), 1)
+	}
+
+	// Pushes which only add commits update the latest version, force-pushes create a new one
+	isAncestor, err := gitRepo.IsAncestor(branches[latest].String(), pr.PullRequest.Head.Sha)
+	if err != nil {
+		klog.Warningf("Error checking whether %s is an ancestor of %s: %s", latest, pr.PullRequest.Head.Sha, err)
+	} else if isAncestor {
+		return latest
+	}
+
 	return fmt.Sprintf(versionedBranchFmt(pr), num+1)
 }
 
-func closeBranches(gitRepo *git.Git, prPayload *github.PullRequestPayload, gh ghclient.GH) error {
-	prNum := int(prPayload.Number)
-	err := gitRepo.EnsureAndFetch(prPayload.PullRequest.User.Login, prPayload.PullRequest.Head.Repo.SSHURL)
+// switchLayout deletes the branches left from before branch-versioning was enabled or disabled, as z_prN/user/branch
+// and z_prN/user/branch/1 can't both exist. If other PRs are based on those branches, they're kept and the PR goes
+// on using them instead of versionBranch, until those PRs are gone.
+func switchLayout(gitRepo *git.Git, pr *github.PullRequestPayload, gh ghclient.GH, branches git.Branches,
+	versionBranch string,
+) string {
+	unversioned := versionedBranch(pr)
+	conflicting := []string{}
+	fallback := ""
+	if versionBranch == unversioned {
+		conflicting = sortedVersionBranches(pr, branches)
+		if len(conflicting) > 0 {
+			fallback = conflicting[len(conflicting)-1]
+		}
+	} else if branches[unversioned] != nil {
+		conflicting = []string{unversioned}
+		fallback = unversioned
+	}
+
+	if len(conflicting) == 0 {
+		return versionBranch
+	}
+
+	for _, branch := range conflicting {
+		dependents, err := gh.ListDependentPRs(branch)
+		if err != nil {
+			klog.Errorf("Error fetching dependent PRs for %s, keeping it: %s", branch, err)
+			return fallback
+		}
+		if len(dependents) > 0 {
+			klog.Infof("Keeping %s as other PRs are based on it, %s won't be used yet", branch, versionBranch)
+			return fallback
+		}
+	}
+
+	klog.Infof("Deleting branches from the previous branch-versioning setting: %v", conflicting)
+	if err := deleteBranches(gitRepo, conflicting); err != nil {
+		return fallback
+	}
+	for _, branch := range conflicting {
+		delete(branches, branch)
+	}
+	return versionBranch
+}
+
+// latestVersionBranch returns the numbered version branch with the highest number, and that number
+func latestVersionBranch(pr *github.PullRequestPayload, branches git.Branches) (string, int) {
+	versions := sortedVersionBranches(pr, branches)
+	if len(versions) == 0 {
+		return "", 0
+	}
+
+	latest := versions[len(versions)-1]
+	return latest, versionNumber(latest)
+}
+
+// sortedVersionBranches returns the numbered version branches, from the oldest to the latest
+func sortedVersionBranches(pr *github.PullRequestPayload, branches git.Branches) []string {
+	versions := []string{}
+	for _, branch := range filterVersionBranches(pr, branches) {
+		if versionNumber(branch) > 0 {
+			versions = append(versions, branch)
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versionNumber(versions[i]) < versionNumber(versions[j])
+	})
+	return versions
+}
+
+func versionNumber(branch string) int {
+	parts := strings.Split(branch, "/")
+	num, _ := strconv.Atoi(parts[len(parts)-1])
+	return num
+}
+
+// rangeDiffMsg describes how the commits changed since the previous version
+func rangeDiffMsg(gitRepo *git.Git, pr *github.PullRequestPayload, previous, previousSHA string) string {
+	entries, err := gitRepo.RangeDiff(pr.PullRequest.Base.Sha, previousSHA, pr.PullRequest.Head.Sha)
 	if err != nil {
-		klog.Errorf("git remote setup failed: %s", err)
-		return err
+		klog.Errorf("Error comparing %s with %s: %s", previous, pr.PullRequest.Head.Sha, err)
+		return ""
+	}
+
+	msg := fmt.Sprintf("\n\nChanges since %s:\n```\n", previous)
+	for _, entry := range entries {
+		msg += fmt.Sprintf("%s %s %s %s\n", shortSHA(entry.OldSHA), entry.Status, shortSHA(entry.NewSHA), entry.Title)
+	}
+	return msg + "```"
+}
+
+func shortSHA(sha string) string {
+	if sha == "" {
+		return "-------"
+	}
+	return sha[:7]
+}
+
+// pruneVersionBranches deletes the oldest version branches beyond the retention, retargeting the PRs based
+// on them to the current version, and returns the deleted branches
+func pruneVersionBranches(gitRepo *git.Git, pr *github.PullRequestPayload, gh ghclient.GH, branches git.Branches,
+	current string, retention int,
+) []string {
+	versions := sortedVersionBranches(pr, branches)
+	if branches[current] == nil {
+		versions = append(versions, current)
+	}
+
+	if len(versions) <= retention {
+		return nil
+	}
+
+	toPrune := versions[:len(versions)-retention]
+	klog.Infof("Pruning version branches: %v", toPrune)
+
+	prNum := int(pr.Number)
+	retargeted, err := gh.UpdateDependingPRs(prNum, current, toPrune)
+	if err != nil {
+		return nil
+	}
+
+	for dependentNum, branch := range retargeted {
+		gh.StickyComment(dependentNum, baseCommentKind,
+			"%s was pruned, so the base of this PR has been updated to %s, the latest version of #%d", branch, current, prNum)
+	}
+
+	if err := gitRepo.DeleteRemoteBranches(toPrune); err != nil {
+		klog.Errorf("Something happened removing branches: %s", err)
+		return nil
+	}
+	return toPrune
+}
+
+// cherryPick opens the backport PRs requested with /cherry-pick before the PR was merged
+func cherryPick(gitRepo *git.Git, pr *github.PullRequestPayload, gh ghclient.GH) {
+	labels := []string{}
+	for _, label := range pr.PullRequest.Labels {
+		labels = append(labels, label.Name)
+	}
+
+	targets := cherrypick.Targets(labels)
+	if len(targets) == 0 || pr.PullRequest.MergeCommitSha == nil {
+		return
+	}
+
+	config, err := repoconfig.Read(gitRepo, pr.PullRequest.Base.Sha)
+	if err != nil {
+		klog.Infof("Error reading bot config: %s", err)
+		return
+	}
+
+	if !config.CommandEnabled("cherry-pick") {
+		klog.Infof("cherry-pick not enabled in bot config for PR %s/#%d", pr.Repository.FullName, pr.Number)
+		return
+	}
+
+	cpPR := &cherrypick.PR{
+		Num:      int(pr.Number),
+		Title:    pr.PullRequest.Title,
+		MergeSHA: *pr.PullRequest.MergeCommitSha,
+	}
+	for _, target := range targets {
+		// Failing to backport shouldn't stop the branches from being closed
+		if err := cherrypick.Run(gh, gitRepo, cpPR, target); err != nil {
+			klog.Errorf("Error cherry-picking PR #%d to %s: %s", cpPR.Num, target, err)
+		}
 	}
+}
 
+func closeBranches(gitRepo *git.Git, prPayload *github.PullRequestPayload, gh ghclient.GH) error {
 	branches, err := gitRepo.GetBranches()
 	if err != nil {
 		klog.Errorf("Error getting branches for origin repo")
 		return nil
 	}
 
-	branchesToDelete := filterVersionBranches(prPayload, branches)
+	versionBranches := filterVersionBranches(prPayload, branches)
+	if prPayload.PullRequest.Merged {
+		return closeMergedBranches(gitRepo, prPayload, gh, branches, versionBranches)
+	}
+	return closeUnmergedBranches(gitRepo, prPayload, gh, branches, versionBranches)
+}
+
+// closeMergedBranches retargets the PRs depending on the merged PR to its base, rebasing them without the merged
+// commits, and deletes the branches
+func closeMergedBranches(gitRepo *git.Git, prPayload *github.PullRequestPayload, gh ghclient.GH, branches git.Branches,
+	branchesToDelete []string,
+) error {
+	if len(branchesToDelete) == 0 {
+		return nil
+	}
+
+	prNum := int(prPayload.Number)
+	baseRef := prPayload.PullRequest.Base.Ref
 	klog.Infof("Deleting branches: %v", branchesToDelete)
 
-	if err = gh.UpdateDependingPRs(prNum, prPayload.PullRequest.Base.Ref, branchesToDelete); err != nil {
+	// A merged PR can't be reopened, so its branches aren't recorded
+	retargeted, err := gh.UpdateDependingPRs(prNum, baseRef, branchesToDelete)
+	if err != nil {
 		return err
 	}
 
-	if err = gitRepo.DeleteRemoteBranches(branchesToDelete); err != nil {
+	baseSHA := ""
+	if branches[baseRef] != nil {
+		baseSHA = branches[baseRef].String()
+	}
+
+	for dependentNum, branch := range retargeted {
+		rebaseDependentPR(gitRepo, prPayload, gh, dependentNum, branches[branch].String(), baseSHA)
+	}
+
+	err = deleteBranches(gitRepo, branchesToDelete)
+	if err == nil {
+		gh.StickyComment(prNum, branchesCommentKind, "Closed branches: %s", branchesToDelete)
+	}
+	return err
+}
+
+// closeUnmergedBranches deletes the branches of a PR closed without merging, except the ones other PRs are based
+// on: those are kept, and the dependent PRs warned, as their changes can't be merged without the closed PR's
+func closeUnmergedBranches(gitRepo *git.Git, prPayload *github.PullRequestPayload, gh ghclient.GH,
+	branches git.Branches, versionBranches []string,
+) error {
+	prNum := int(prPayload.Number)
+	branchesToDelete := []string{}
+	kept := []string{}
+	for _, branch := range versionBranches {
+		dependents, err := gh.ListDependentPRs(branch)
+		if err != nil {
+			klog.Errorf("Error fetching dependent PRs for %s: %s", branch, err)
+			return err
+		}
+
+		if len(dependents) == 0 {
+			branchesToDelete = append(branchesToDelete, branch)
+			continue
+		}
+
+		kept = append(kept, branch)
+		for _, dependent := range dependents {
+			gh.StickyComment(dependent.GetNumber(), baseCommentKind, "⚠️ #%d, which this PR is based on, was closed without being merged. "+
+				"I'm keeping its branch %s so this PR isn't closed, please rebase this PR and change its base.", prNum, branch)
+		}
+	}
+
+	keptMsg := ""
+	if len(kept) > 0 {
+		klog.Infof("Keeping branches with dependent PRs: %v", kept)
+		keptMsg = fmt.Sprintf("Kept branches other PRs are based on: %s", kept)
+	}
+
+	if len(branchesToDelete) == 0 {
+		if keptMsg != "" {
+			gh.StickyComment(prNum, branchesCommentKind, "%s", keptMsg)
+		}
+		return nil
+	}
+
+	klog.Infof("Deleting branches: %v", branchesToDelete)
+	if err := recordClosedPR(gitRepo, prNum, branches, branchesToDelete); err != nil {
+		return err
+	}
+	err := deleteBranches(gitRepo, branchesToDelete)
+	if err == nil {
+		gh.StickyComment(prNum, branchesCommentKind, "Closed branches: %s\n%s", branchesToDelete, keptMsg)
+	}
+	return err
+}
+
+func deleteBranches(gitRepo *git.Git, branchesToDelete []string) error {
+	err := gitRepo.DeleteRemoteBranches(branchesToDelete)
+	if err != nil {
 		klog.Errorf("Something happened removing branches: %s", err)
-	} else {
-		gh.CommentOnPR(prNum, "Closed branches: %s", branchesToDelete)
 	}
 	return err
 }
 
+// pruneContributorRemote removes the remote of a contributor with no other open PRs, so their branches
+// don't take space in the clone
+func pruneContributorRemote(gitRepo *git.Git, gh ghclient.GH, login string) {
+	if login == git.Origin {
+		return
+	}
+
+	openPRs, err := gh.ListOpenPRs()
+	if err != nil {
+		klog.Errorf("Error listing the open PRs to check the remote of %s: %s", login, err)
+		return
+	}
+
+	for _, pr := range openPRs {
+		if pr.GetUser().GetLogin() == login {
+			return
+		}
+	}
+
+	if err := gitRepo.RemoveRemote(login); err != nil {
+		klog.Errorf("Error removing the remote of %s: %s", login, err)
+	}
+}
+
+// rebaseDependentPR replays the commits of a dependent PR which aren't in the merged PR's branch onto the new base,
+// and pushes them to the dependent PR's branch. If that's not possible, its author is asked to do it.
+func rebaseDependentPR(gitRepo *git.Git, prPayload *github.PullRequestPayload, gh ghclient.GH, dependentNum int,
+	parentSHA, baseSHA string,
+) {
+	prNum := int(prPayload.Number)
+	baseRef := prPayload.PullRequest.Base.Ref
+	askToRebase := func(reason string) {
+		klog.Infof("Not rebasing dependent PR #%d: %s", dependentNum, reason)
+		gh.StickyComment(dependentNum, baseCommentKind, "#%d has been merged, so the base of this PR has been updated to %s\n"+
+			"I couldn't rebase it (%s), please rebase this branch and remove #%d related commits",
+			prNum, baseRef, reason, prNum)
+	}
+
+	dependent, err := gh.GetPR(dependentNum)
+	if err != nil {
+		askToRebase(err.Error())
+		return
+	}
+
+	if baseSHA == "" {
+		askToRebase("the base branch wasn't found")
+		return
+	}
+
+	headRepo := dependent.GetHead().GetRepo()
+	if headRepo.GetFullName() != prPayload.PullRequest.Base.Repo.FullName && !dependent.GetMaintainerCanModify() {
+		askToRebase("edits from maintainers aren't allowed")
+		return
+	}
+
+	remote := dependent.GetUser().GetLogin()
+	err = gitRepo.EnsureAndFetch(remote, gitRepo.RemoteURL(headRepo.GetSSHURL(), headRepo.GetCloneURL()),
+		dependent.GetHead().GetRef())
+	if err != nil {
+		askToRebase(err.Error())
+		return
+	}
+
+	headSHA := dependent.GetHead().GetSHA()
+	commits, err := gitRepo.CommitsInRange(parentSHA, headSHA)
+	if err != nil {
+		askToRebase(err.Error())
+		return
+	}
+
+	rebaseBranch := fmt.Sprintf("z_rebase%d", dependentNum)
+	if err := gitRepo.CherryPick(rebaseBranch, baseSHA, commits); err != nil {
+		askToRebase(err.Error())
+		return
+	}
+
+	if err := gitRepo.PushToRemote(remote, rebaseBranch, dependent.GetHead().GetRef(), headSHA); err != nil {
+		askToRebase(err.Error())
+		return
+	}
+
+	gh.StickyComment(dependentNum, baseCommentKind, "#%d has been merged, so the base of this PR has been updated to %s "+
+		"and I rebased it without the commits from #%d", prNum, baseRef, prNum)
+}
+
+// recordClosedPR keeps the heads of the branches being deleted on origin, in case the PR is reopened. The branches
+// can't be restored if that fails, so they mustn't be deleted then.
+func recordClosedPR(gitRepo *git.Git, prNum int, branches git.Branches, branchesToDelete []string) error {
+	heads := make(git.Branches)
+	for _, branch := range branchesToDelete {
+		heads[branch] = branches[branch]
+	}
+
+	err := gitRepo.ArchiveBranches(prNum, heads)
+	if err != nil {
+		klog.Errorf("Error keeping the heads of the closed branches of PR #%d: %s", prNum, err)
+	}
+	return err
+}
+
+// restoreBranches recreates the branches deleted when the PR was closed, from the heads kept by recordClosedPR
+func restoreBranches(gitRepo *git.Git, prPayload *github.PullRequestPayload, gh ghclient.GH) error {
+	prNum := int(prPayload.Number)
+	closed, err := gitRepo.FetchClosedBranches(prNum)
+	if err != nil {
+		klog.Errorf("Error fetching the closed branches of PR #%d: %s", prNum, err)
+		return err
+	}
+
+	if len(closed) == 0 {
+		klog.Infof("No closed branches kept for PR #%d", prNum)
+		return nil
+	}
+
+	branches, err := gitRepo.GetBranches()
+	if err != nil {
+		klog.Errorf("Error getting branches for origin repo: %s", err)
+		return err
+	}
+
+	restored := []string{}
+	failed := []string{}
+	// The heads of the branches which couldn't be restored are kept, to try again when the PR is reopened next
+	done := []string{}
+	for branch, hash := range closed {
+		// Someone may have pushed it again while the PR was closed
+		if branches[branch] == nil {
+			if err := restoreBranch(gitRepo, branch, hash.String()); err != nil {
+				klog.Errorf("Error restoring branch %s at %s: %s", branch, hash, err)
+				failed = append(failed, fmt.Sprintf("- %s: %s", branch, err))
+				continue
+			}
+			restored = append(restored, branch)
+		}
+		done = append(done, branch)
+	}
+
+	sort.Strings(restored)
+	sort.Strings(failed)
+	switch {
+	case len(failed) > 0:
+		gh.StickyComment(prNum, branchesCommentKind, "Restored branches: %s\n⚠️ I couldn't restore these branches:\n%s",
+			restored, strings.Join(failed, "\n"))
+	case len(restored) > 0:
+		gh.StickyComment(prNum, branchesCommentKind, "Restored branches: %s", restored)
+	}
+
+	if err := gitRepo.DeleteClosedBranches(prNum, done); err != nil {
+		klog.Errorf("Error deleting the kept heads of the restored branches of PR #%d: %s", prNum, err)
+	}
+	return nil
+}
+
+func restoreBranch(gitRepo *git.Git, branch, sha string) error {
+	if err := gitRepo.CreateBranch(branch, sha); err != nil {
+		return err
+	}
+	return gitRepo.Push(branch)
+}
+
 func filterVersionBranches(pr *github.PullRequestPayload, branches git.Branches) []string {
 	branchesToDelete := []string{}
 	verBase := versionedBranch(pr) + "/"
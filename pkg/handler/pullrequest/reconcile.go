@@ -0,0 +1,27 @@
+package pullrequest
+
+import (
+	"github.com/go-playground/webhooks/v6/github"
+	"k8s.io/klog"
+
+	"github.com/submariner-io/submariner-bot/pkg/ghclient"
+	"github.com/submariner-io/submariner-bot/pkg/git"
+)
+
+// Reconcile creates or updates the z_pr branch of a PR if none points at its head, as if the missed event
+// which changed the head was handled
+func Reconcile(gitRepo *git.Git, gh ghclient.GH, pr *github.PullRequestPayload, branches git.Branches) error {
+	// PRs from local branches don't get z_pr branches
+	if pr.PullRequest.Base.Repo.FullName == pr.PullRequest.Head.Repo.FullName {
+		return nil
+	}
+
+	for _, branch := range filterVersionBranches(pr, branches) {
+		if branches[branch].String() == pr.PullRequest.Head.Sha {
+			return nil
+		}
+	}
+
+	klog.Infof("No z_pr branch of PR %d points at its head %s, syncing it", pr.Number, pr.PullRequest.Head.Sha)
+	return openOrSync(gitRepo, pr, gh)
+}
@@ -0,0 +1,122 @@
+package pullrequest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gogithub "github.com/google/go-github/v28/github"
+	"k8s.io/klog"
+
+	"github.com/submariner-io/submariner-bot/pkg/ghclient"
+	"github.com/submariner-io/submariner-bot/pkg/git"
+)
+
+const stackCommentKind = "stack"
+
+var prBranchRegexp = regexp.MustCompile(`^z_pr(\d+)/`)
+
+// prBranches groups the z_pr branches by the number of the PR they were created for
+func prBranches(branches git.Branches) map[int][]string {
+	grouped := make(map[int][]string)
+	for branch := range branches {
+		if match := prBranchRegexp.FindStringSubmatch(branch); match != nil {
+			num, _ := strconv.Atoi(match[1])
+			grouped[num] = append(grouped[num], branch)
+		}
+	}
+	return grouped
+}
+
+// stackMembers returns the numbers of the PRs in the same stack as the given PR
+func stackMembers(gitRepo *git.Git, gh ghclient.GH, prNum int) []int {
+	stack, err := getStack(gitRepo, gh, prNum)
+	if err != nil {
+		return nil
+	}
+
+	members := []int{}
+	for _, member := range stack.Members() {
+		members = append(members, member.GetNumber())
+	}
+	return members
+}
+
+func getStack(gitRepo *git.Git, gh ghclient.GH, prNum int) (*ghclient.StackNode, error) {
+	branches, err := gitRepo.GetBranches()
+	if err != nil {
+		klog.Errorf("Error getting branches for origin repo: %s", err)
+		return nil, err
+	}
+
+	stack, err := gh.GetPRStack(prNum, prBranches(branches))
+	if err != nil {
+		klog.Errorf("Error getting the stack of PR #%d: %s", prNum, err)
+	}
+	return stack, err
+}
+
+// updateStackComments refreshes the stack comment on every open PR in the stacks of the given PRs
+func updateStackComments(gitRepo *git.Git, gh ghclient.GH, prNums []int) {
+	updated := make(map[int]bool)
+	for _, prNum := range prNums {
+		if updated[prNum] {
+			continue
+		}
+
+		stack, err := getStack(gitRepo, gh, prNum)
+		if err != nil {
+			continue
+		}
+
+		members := stack.Members()
+		for _, member := range members {
+			num := member.GetNumber()
+			updated[num] = true
+			if member.GetState() != "open" {
+				continue
+			}
+
+			if len(members) == 1 {
+				// Not stacked (anymore), only refresh a comment left from when it was
+				gh.UpdateStickyComment(num, stackCommentKind, "This PR isn't part of a stack anymore.")
+			} else {
+				gh.StickyComment(num, stackCommentKind, "This PR is part of a stack:\n%s", renderStack(stack, num))
+			}
+		}
+	}
+}
+
+// renderStack draws the stack as nested lists, starting with the branch the root PR is based on
+func renderStack(stack *ghclient.StackNode, current int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "- `%s`\n", stack.PR.GetBase().GetRef())
+	renderStackNode(&sb, stack, current, 1)
+	return sb.String()
+}
+
+func renderStackNode(sb *strings.Builder, node *ghclient.StackNode, current, depth int) {
+	pr := node.PR
+	fmt.Fprintf(sb, "%s- #%d %s %s", strings.Repeat("  ", depth), pr.GetNumber(), prStatus(pr), pr.GetTitle())
+	if pr.GetNumber() == current {
+		sb.WriteString(" 👈 this PR")
+	}
+	sb.WriteString("\n")
+
+	for _, dependent := range node.Dependents {
+		renderStackNode(sb, dependent, current, depth+1)
+	}
+}
+
+func prStatus(pr *gogithub.PullRequest) string {
+	switch {
+	case pr.GetMerged() || pr.MergedAt != nil:
+		return "🟣 merged"
+	case pr.GetState() == "closed":
+		return "🔴 closed"
+	case pr.GetDraft():
+		return "⚪ draft"
+	}
+	return "🟢 open"
+}
@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"strings"
+
 	"github.com/go-playground/webhooks/v6/github"
+	gogithub "github.com/google/go-github/v28/github"
 	"k8s.io/klog"
 
 	"github.com/submariner-io/submariner-bot/pkg/config/repoconfig"
@@ -18,7 +21,8 @@ func handlePullRequestReview(prr github.PullRequestReviewPayload) error {
 		return err
 	}
 
-	gitRepo, err := git.New(prr.PullRequest.Base.Repo.FullName, prr.PullRequest.Base.Repo.SSHURL)
+	gitRepo, err := git.New(prr.PullRequest.Base.Repo.FullName, prr.PullRequest.Base.Repo.SSHURL,
+		prr.PullRequest.Base.Repo.CloneURL)
 	if err != nil {
 		klog.Errorf("creating git object: %s", err)
 		return err
@@ -27,6 +31,12 @@ func handlePullRequestReview(prr github.PullRequestReviewPayload) error {
 	gitRepo.Lock()
 	defer gitRepo.Unlock()
 
+	return labelIfApproved(gitRepo, gh, &prr)
+}
+
+// labelIfApproved adds the label-approved label to the PR once it has enough approvals and no changes requested
+func labelIfApproved(gitRepo *git.Git, gh ghclient.GH, prr *github.PullRequestReviewPayload) error {
+	prNum := int(prr.PullRequest.Number)
 	config, err := repoconfig.Read(gitRepo, prr.PullRequest.Base.Sha)
 	if err != nil {
 		klog.Infof("Error reading bot config: %s", err)
@@ -44,10 +54,20 @@ func handlePullRequestReview(prr github.PullRequestReviewPayload) error {
 		return err
 	}
 
+	// Approvals of previous commits don't count if they have to be renewed after every push
+	headOnly := config.ResetsApprovalOnPush(prr.PullRequest.User.Login)
+	latest := latestReviewStates(reviews, prr.PullRequest.User.Login)
+
 	approvals := 0
-	for _, review := range reviews {
-		if *review.State == "APPROVED" {
-			approvals++
+	for reviewer, review := range latest {
+		switch review.GetState() {
+		case "CHANGES_REQUESTED":
+			klog.Infof("%s requested changes on PR #%d, not adding the label", reviewer, prNum)
+			return nil
+		case "APPROVED":
+			if !headOnly || review.GetCommitID() == prr.PullRequest.Head.Sha {
+				approvals++
+			}
 		}
 	}
 
@@ -67,3 +87,23 @@ func handlePullRequestReview(prr github.PullRequestReviewPayload) error {
 
 	return nil
 }
+
+// latestReviewStates reduces the reviews, in the order they were submitted, to the latest one which approved or
+// requested changes per reviewer. Reviewers whose latest such review was dismissed, the PR author and bots are left out.
+func latestReviewStates(reviews []*gogithub.PullRequestReview, author string) map[string]*gogithub.PullRequestReview {
+	latest := make(map[string]*gogithub.PullRequestReview)
+	for _, review := range reviews {
+		reviewer := review.GetUser().GetLogin()
+		if reviewer == author || review.GetUser().GetType() == "Bot" || strings.HasSuffix(reviewer, "[bot]") {
+			continue
+		}
+
+		switch review.GetState() {
+		case "APPROVED", "CHANGES_REQUESTED":
+			latest[reviewer] = review
+		case "DISMISSED":
+			delete(latest, reviewer)
+		}
+	}
+	return latest
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/webhooks/v6/github"
+	gogithub "github.com/google/go-github/v28/github"
+	"k8s.io/klog"
+
+	"github.com/submariner-io/submariner-bot/pkg/ghclient"
+	"github.com/submariner-io/submariner-bot/pkg/git"
+	"github.com/submariner-io/submariner-bot/pkg/handler/pullrequest"
+)
+
+// Reconcile catches up with the events missed for a repository, making sure the z_pr branches of its open PRs
+// point at their heads and the approved ones are labeled
+func Reconcile(repo string) error {
+	owner, name, found := strings.Cut(repo, "/")
+	if !found {
+		return fmt.Errorf("%q isn't a full repository name", repo)
+	}
+
+	gh, err := ghclient.New(owner, name)
+	if err != nil {
+		return err
+	}
+
+	sshURL, cloneURL := git.GitHubURLs(repo)
+	gitRepo, err := git.New(repo, sshURL, cloneURL)
+	if err != nil {
+		return err
+	}
+
+	gitRepo.Lock()
+	defer gitRepo.Unlock()
+
+	openPRs, err := gh.ListOpenPRs()
+	if err != nil {
+		return err
+	}
+
+	branches, err := gitRepo.GetBranches()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, pr := range openPRs {
+		if err := reconcilePR(gitRepo, gh, pr, branches); err != nil {
+			klog.Errorf("Error reconciling PR %d of %s: %s", pr.GetNumber(), repo, err)
+			failed++
+		}
+	}
+
+	klog.Infof("Reconciled %d open PRs of %s, %d failed", len(openPRs), repo, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d open PRs couldn't be reconciled", failed, len(openPRs))
+	}
+	return nil
+}
+
+func reconcilePR(gitRepo *git.Git, gh ghclient.GH, pr *gogithub.PullRequest, branches git.Branches) error {
+	prPayload := &github.PullRequestPayload{}
+	if err := asPayload(pr, "synchronize", prPayload); err != nil {
+		return err
+	}
+
+	if err := pullrequest.Reconcile(gitRepo, gh, prPayload, branches); err != nil {
+		return err
+	}
+
+	prrPayload := &github.PullRequestReviewPayload{}
+	if err := asPayload(pr, "submitted", prrPayload); err != nil {
+		return err
+	}
+
+	return labelIfApproved(gitRepo, gh, prrPayload)
+}
+
+// asPayload fills a webhook payload with a PR fetched from the API, webhooks use the same JSON representation
+func asPayload(pr *gogithub.PullRequest, action string, payload interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"action":       action,
+		"number":       pr.GetNumber(),
+		"pull_request": pr,
+		"repository":   pr.GetBase().GetRepo(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, payload)
+}
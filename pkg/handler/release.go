@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"github.com/go-playground/webhooks/v6/github"
+	"k8s.io/klog"
+
+	"github.com/submariner-io/submariner-bot/pkg/config/repoconfig"
+	"github.com/submariner-io/submariner-bot/pkg/ghclient"
+	"github.com/submariner-io/submariner-bot/pkg/git"
+)
+
+// releasedCommentKind is the kind of the sticky comments on PRs included in a release, suffixed with the tag
+const releasedCommentKind = "released"
+
+func handleRelease(rp github.ReleasePayload) error {
+	if rp.Action != "published" {
+		return nil
+	}
+
+	tag := rp.Release.TagName
+	klog.Infof("handling release %s on %s", tag, rp.Repository.FullName)
+	gh, err := ghclient.New(rp.Repository.Owner.Login, rp.Repository.Name)
+	if err != nil {
+		klog.Errorf("creating github client: %s", err)
+		return err
+	}
+
+	gitRepo, err := git.New(rp.Repository.FullName, rp.Repository.SSHURL, rp.Repository.CloneURL)
+	if err != nil {
+		klog.Errorf("creating git object: %s", err)
+		return err
+	}
+
+	gitRepo.Lock()
+	defer gitRepo.Unlock()
+
+	sha, err := gitRepo.ResolveTag(tag)
+	if err != nil {
+		klog.Errorf("resolving tag %s: %s", tag, err)
+		return err
+	}
+
+	config, err := repoconfig.Read(gitRepo, sha)
+	if err != nil {
+		klog.Infof("Error reading bot config: %s", err)
+		return err
+	}
+
+	if config.CommentReleased == nil {
+		klog.Infof("comment when released not enabled in bot config for %s", rp.Repository.FullName)
+		return nil
+	}
+
+	previousTag, commits, err := gitRepo.CommitsSinceTag(tag)
+	if err != nil {
+		klog.Errorf("finding commits included in %s: %s", tag, err)
+		return err
+	}
+
+	if previousTag == "" {
+		klog.Infof("no tag found before %s, not commenting on any PR", tag)
+		return nil
+	}
+
+	klog.Infof("%d commits included in %s since %s", len(commits), tag, previousTag)
+
+	prNums := []int{}
+	seen := make(map[int]bool)
+	for _, sha := range commits {
+		prs, err := gh.ListMergedPRsWithCommit(sha)
+		if err != nil {
+			klog.Errorf("listing PRs with commit %s: %s", sha, err)
+			return err
+		}
+
+		for _, pr := range prs {
+			if !seen[*pr.Number] {
+				seen[*pr.Number] = true
+				prNums = append(prNums, *pr.Number)
+			}
+		}
+	}
+
+	label := *config.CommentReleased.LabelPrefix + tag
+	for _, prNum := range prNums {
+		// The comment is sticky per tag, so handling the release again doesn't repeat it
+		gh.StickyComment(prNum, releasedCommentKind+":"+tag, "This PR has been released in [%s](%s)", tag, rp.Release.HTMLURL)
+
+		if *config.CommentReleased.Label {
+			klog.Infof("adding label %s to PR #%d", label, prNum)
+			if err := gh.AddLabel(prNum, label); err != nil {
+				klog.Errorf("error while adding label %s to PR #%d: %s", label, prNum, err)
+				return err
+			}
+		}
+	}
+
+	return nil
+}
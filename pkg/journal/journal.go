@@ -0,0 +1,265 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"k8s.io/klog"
+)
+
+const (
+	// maxAttempts is the number of times an entry is replayed before giving up on it
+	maxAttempts = 3
+	// compactThreshold is the size past which the journal is compacted while running, it's also compacted
+	// whenever no entry is pending
+	compactThreshold = 64 * 1024 * 1024
+)
+
+// Entry is a webhook delivery as received from GitHub, so it can be parsed again when replayed
+type Entry struct {
+	ID        string `json:"id"`
+	Event     string `json:"event,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Body      []byte `json:"body,omitempty"`
+	Attempts  int    `json:"attempts,omitempty"`
+	Done      bool   `json:"done,omitempty"`
+}
+
+// Journal is an append-only file of accepted deliveries, and markers for the ones already handled
+type Journal struct {
+	lock sync.Mutex
+	file *os.File
+	path string
+	// pending holds the entries not marked as done yet, in the order they were appended, to compact the file
+	pending map[string]*Entry
+	order   []string
+	size    int64
+	// compactAt is the size at which the file is compacted next
+	compactAt int64
+}
+
+// Open opens the journal at path, returning the entries which were never marked as done, in the order
+// they were appended. The file is compacted so it only contains those entries.
+func Open(path string) (*Journal, []*Entry, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, nil, err
+	}
+
+	pending, err := readPending(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pending = dropExhausted(pending)
+
+	if err := compact(path, pending); err != nil {
+		return nil, nil, err
+	}
+
+	j := &Journal{path: path, pending: make(map[string]*Entry)}
+	for _, entry := range pending {
+		j.pending[entry.ID] = entry
+		j.order = append(j.order, entry.ID)
+	}
+
+	if err := j.reopen(); err != nil {
+		return nil, nil, err
+	}
+
+	klog.Infof("Journal %s opened with %d pending entries", path, len(pending))
+	return j, pending, nil
+}
+
+// Append persists an entry, it returns once the entry is synced to disk
+func (j *Journal) Append(entry *Entry) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if err := j.write(entry); err != nil {
+		return err
+	}
+
+	if _, ok := j.pending[entry.ID]; !ok {
+		j.order = append(j.order, entry.ID)
+	}
+	j.pending[entry.ID] = entry
+	return nil
+}
+
+// Done marks the entry with the given id as handled, so it won't be replayed. The file is compacted once no
+// entry is pending anymore, or when it gets too big.
+func (j *Journal) Done(id string) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if err := j.write(&Entry{ID: id, Done: true}); err != nil {
+		return err
+	}
+	delete(j.pending, id)
+
+	if len(j.pending) > 0 && j.size < j.compactAt {
+		return nil
+	}
+
+	if err := j.compact(); err != nil {
+		// The entries are still in the file, compacting is attempted again on the next Done
+		klog.Errorf("Error compacting journal %s: %s", j.path, err)
+	}
+	return nil
+}
+
+func (j *Journal) write(entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	n, err := j.file.Write(append(line, '\n'))
+	j.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("writing to journal %s: %s", j.path, err)
+	}
+	return j.file.Sync()
+}
+
+// compact rewrites the file with only the pending entries, the lock must be held
+func (j *Journal) compact() error {
+	order := []string{}
+	entries := []*Entry{}
+	for _, id := range j.order {
+		if entry, ok := j.pending[id]; ok {
+			order = append(order, id)
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := compact(j.path, entries); err != nil {
+		return err
+	}
+	j.order = order
+
+	if err := j.file.Close(); err != nil {
+		klog.Warningf("Error closing the compacted journal %s: %s", j.path, err)
+	}
+	return j.reopen()
+}
+
+// reopen opens the file for appending, and sets when it must be compacted next from its size
+func (j *Journal) reopen() error {
+	file, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	j.file, j.size = file, info.Size()
+	// Pending entries alone could be past the threshold, this avoids compacting again on every Done
+	j.compactAt = compactThreshold
+	if 2*j.size > j.compactAt {
+		j.compactAt = 2 * j.size
+	}
+	return nil
+}
+
+func readPending(path string) ([]*Entry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	order := []string{}
+	entries := make(map[string]*Entry)
+
+	scanner := bufio.NewScanner(file)
+	// payloads for big PRs can be well beyond the default token size
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		entry := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			// A crash in the middle of a write can leave a truncated last line
+			klog.Warningf("Skipping unreadable journal line in %s: %s", path, err)
+			continue
+		}
+
+		if entry.Done {
+			delete(entries, entry.ID)
+			continue
+		}
+
+		if _, ok := entries[entry.ID]; !ok {
+			order = append(order, entry.ID)
+		}
+		entries[entry.ID] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	pending := []*Entry{}
+	for _, id := range order {
+		if entry, ok := entries[id]; ok {
+			pending = append(pending, entry)
+		}
+	}
+	return pending, nil
+}
+
+func dropExhausted(entries []*Entry) []*Entry {
+	pending := []*Entry{}
+	for _, entry := range entries {
+		entry.Attempts++
+		if entry.Attempts > maxAttempts {
+			klog.Warningf("Dropping %s delivery %s from journal after %d attempts", entry.Event, entry.ID, maxAttempts)
+			continue
+		}
+		pending = append(pending, entry)
+	}
+	return pending
+}
+
+func compact(path string, entries []*Entry) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if _, err := writer.Write(append(line, '\n')); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,159 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOpenReplaysPendingEntriesInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	j := open(t, path, nil)
+
+	for _, id := range []string{"a", "b", "c", "d"} {
+		appendEntry(t, j, id)
+	}
+	// Appending an entry again updates it, it keeps its place
+	appendEntry(t, j, "b")
+	done(t, j, "a")
+	done(t, j, "c")
+
+	j = open(t, path, []string{"b", "d"})
+	for _, entry := range j.pending {
+		if entry.Attempts != 1 {
+			t.Errorf("Entry %s has %d attempts, expected 1", entry.ID, entry.Attempts)
+		}
+	}
+}
+
+func TestOpenSkipsATruncatedLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	j := open(t, path, nil)
+	appendEntry(t, j, "a")
+
+	// A crash in the middle of a write leaves a partial line
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"id":"b","event":"pull_req`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	j = open(t, path, []string{"a"})
+	if ids := fileIDs(t, path); !reflect.DeepEqual(ids, []string{"a"}) {
+		t.Fatalf("The journal holds %v once compacted, expected [a]", ids)
+	}
+
+	// Entries appended after the truncated line are read back
+	appendEntry(t, j, "c")
+	open(t, path, []string{"a", "c"})
+}
+
+func TestOpenDropsExhaustedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	j := open(t, path, nil)
+	appendEntry(t, j, "a")
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		j = open(t, path, []string{"a"})
+		if attempts := j.pending["a"].Attempts; attempts != attempt {
+			t.Fatalf("Entry a has %d attempts once opened %d times, expected %d", attempts, attempt, attempt)
+		}
+	}
+
+	appendEntry(t, j, "b")
+	open(t, path, []string{"b"})
+}
+
+func TestDoneCompactsWhileRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	j := open(t, path, nil)
+
+	appendEntry(t, j, "a")
+	appendEntry(t, j, "b")
+	done(t, j, "a")
+	if ids := fileIDs(t, path); !reflect.DeepEqual(ids, []string{"a", "b", "a"}) {
+		t.Fatalf("The journal holds %v while under the threshold, expected [a b a]", ids)
+	}
+
+	// Past the threshold, the file is compacted even with pending entries
+	appendEntry(t, j, "c")
+	j.compactAt = j.size
+	done(t, j, "c")
+	if ids := fileIDs(t, path); !reflect.DeepEqual(ids, []string{"b"}) {
+		t.Fatalf("The journal holds %v once compacted, expected [b]", ids)
+	}
+
+	// The compacted file is appended to
+	appendEntry(t, j, "d")
+	if ids := fileIDs(t, path); !reflect.DeepEqual(ids, []string{"b", "d"}) {
+		t.Fatalf("The journal holds %v after an append, expected [b d]", ids)
+	}
+
+	// Once no entry is pending, the file is emptied
+	done(t, j, "b")
+	done(t, j, "d")
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Size() != 0 {
+		t.Fatalf("The journal is %d bytes long with no pending entries, expected 0", info.Size())
+	}
+	open(t, path, []string{})
+}
+
+// open opens the journal at path, checking the ids of the pending entries returned if expected isn't nil
+func open(t *testing.T, path string, expected []string) *Journal {
+	j, pending, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %s", err)
+	}
+	t.Cleanup(func() { j.file.Close() })
+
+	if expected != nil {
+		ids := []string{}
+		for _, entry := range pending {
+			ids = append(ids, entry.ID)
+		}
+		if !reflect.DeepEqual(ids, expected) {
+			t.Fatalf("Open returned the pending entries %v, expected %v", ids, expected)
+		}
+	}
+	return j
+}
+
+func appendEntry(t *testing.T, j *Journal, id string) {
+	if err := j.Append(&Entry{ID: id, Event: "pull_request", Body: []byte(`{"action":"opened"}`)}); err != nil {
+		t.Fatalf("Append(%s) failed: %s", id, err)
+	}
+}
+
+func done(t *testing.T, j *Journal, id string) {
+	if err := j.Done(id); err != nil {
+		t.Fatalf("Done(%s) failed: %s", id, err)
+	}
+}
+
+// fileIDs returns the ids of the lines in the journal file, in order
+func fileIDs(t *testing.T, path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ids := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
@@ -4,10 +4,17 @@ import (
 	"net/http"
 
 	"github.com/go-playground/webhooks/v6/github"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/klog"
 
 	"github.com/submariner-io/submariner-bot/pkg/config"
+	"github.com/submariner-io/submariner-bot/pkg/deliveries"
+	"github.com/submariner-io/submariner-bot/pkg/git"
 	"github.com/submariner-io/submariner-bot/pkg/handler"
+	"github.com/submariner-io/submariner-bot/pkg/handler/pullrequest"
+	"github.com/submariner-io/submariner-bot/pkg/journal"
+	"github.com/submariner-io/submariner-bot/pkg/metrics"
+	"github.com/submariner-io/submariner-bot/pkg/queue"
 )
 
 const (
@@ -24,26 +31,37 @@ func main() {
 
 	hook, _ := github.New(github.Options.Secret(webhookSecret))
 
-	http.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-		payload, err := hook.Parse(r, handler.EventsToHandle()...)
-		if err != nil {
-			if err == github.ErrEventNotFound {
-				// ok event wasn't one of the ones asked to be parsed
-			} else {
-				w.WriteHeader(500)
-			}
-			return
-		}
-		err = handler.Handle(payload)
-		if err != nil {
-			w.WriteHeader(500)
-			_, err := w.Write([]byte("An error happened: " + err.Error()))
-			if err != nil {
-				klog.Errorf("Failed to write response: %s", err)
-			}
-		}
+	journalPath := config.GetJournalPath()
+	eventJournal, pending, err := journal.Open(journalPath)
+	if err != nil {
+		klog.Fatalf("Error opening the event journal %s: %s", journalPath, err)
+	}
+
+	s := &server{
+		hook:       hook,
+		queue:      queue.New(config.GetQueueWorkers(), config.GetQueueCapacity()),
+		journal:    eventJournal,
+		deliveries: deliveries.NewStore(config.GetDeliveriesWindow(), config.GetDeliveriesCapacity()),
+	}
+	metrics.RegisterQueueDepth(func() float64 {
+		return float64(s.queue.Len())
+	})
+	s.replay(pending)
+
+	diskBudget := config.GetGitDiskBudget()
+	every(config.GetGitMaintenanceInterval(), func() {
+		git.Maintain(diskBudget)
 	})
 
+	dryRun := config.IsBranchGCDryRun()
+	s.schedule("branch GC", config.GetBranchGCInterval(), config.GetRepositories(), func(repo string) error {
+		return pullrequest.CollectOrphanedBranches(repo, dryRun)
+	})
+	s.schedule("reconciliation", config.GetReconcileInterval(), config.GetRepositories(), handler.Reconcile)
+
+	http.HandleFunc(path, s.handleWebhook)
+	http.Handle("/metrics", promhttp.Handler())
+
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "GET" {
 			w.WriteHeader(200)
@@ -0,0 +1,15 @@
+package main
+
+import "time"
+
+// every runs task in the background each time interval elapses
+func every(interval time.Duration, task func()) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			task()
+		}
+	}()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/webhooks/v6/github"
+	"k8s.io/klog"
+
+	"github.com/submariner-io/submariner-bot/pkg/deliveries"
+	"github.com/submariner-io/submariner-bot/pkg/handler"
+	"github.com/submariner-io/submariner-bot/pkg/journal"
+	"github.com/submariner-io/submariner-bot/pkg/metrics"
+	"github.com/submariner-io/submariner-bot/pkg/queue"
+)
+
+// forceReplayHeader can be set to "true" to handle a delivery even if it was already handled successfully
+const forceReplayHeader = "X-Submariner-Bot-Force-Replay"
+
+type server struct {
+	hook       *github.Webhook
+	queue      *queue.Queue
+	journal    *journal.Journal
+	deliveries *deliveries.Store
+}
+
+func (s *server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	entry := &journal.Entry{
+		ID:        r.Header.Get("X-GitHub-Delivery"),
+		Event:     r.Header.Get("X-GitHub-Event"),
+		Signature: r.Header.Get("X-Hub-Signature"),
+		Body:      body,
+	}
+	if entry.ID == "" {
+		entry.ID = fmt.Sprintf("local-%d", time.Now().UnixNano())
+	}
+
+	payload, err := s.parse(r.Method, entry)
+	if err != nil {
+		if err == github.ErrEventNotFound {
+			// ok event wasn't one of the ones asked to be parsed
+		} else {
+			w.WriteHeader(500)
+		}
+		return
+	}
+	metrics.EventsReceived.WithLabelValues(entry.Event, handler.ActionOf(payload)).Inc()
+
+	if s.deliveries.Handled(entry.ID) {
+		if r.Header.Get(forceReplayHeader) != "true" {
+			klog.Infof("Skipping %s delivery %s, it was already handled", entry.Event, entry.ID)
+			w.WriteHeader(200)
+			return
+		}
+		klog.Infof("Forcing the replay of %s delivery %s", entry.Event, entry.ID)
+	}
+
+	if err := s.journal.Append(entry); err != nil {
+		klog.Errorf("Error persisting %s delivery %s: %s", entry.Event, entry.ID, err)
+		writeError(w, 500, err)
+		return
+	}
+
+	if err := s.enqueue(entry, payload); err != nil {
+		klog.Errorf("Rejecting %s delivery %s: %s", entry.Event, entry.ID, err)
+		// GitHub will show the delivery as failed, so it can be redelivered from there
+		if err := s.journal.Done(entry.ID); err != nil {
+			klog.Errorf("Error marking delivery %s as done: %s", entry.ID, err)
+		}
+		writeError(w, 503, err)
+		return
+	}
+	w.WriteHeader(202)
+}
+
+// parse verifies and parses a delivery through the webhook library, as if it was received again
+func (s *server) parse(method string, entry *journal.Entry) (interface{}, error) {
+	r, err := http.NewRequest(method, path, bytes.NewReader(entry.Body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("X-GitHub-Delivery", entry.ID)
+	r.Header.Set("X-GitHub-Event", entry.Event)
+	r.Header.Set("X-Hub-Signature", entry.Signature)
+
+	return s.hook.Parse(r, handler.EventsToHandle()...)
+}
+
+func (s *server) enqueue(entry *journal.Entry, payload interface{}) error {
+	return s.queue.Enqueue(handler.RepositoryOf(payload), func() {
+		action := handler.ActionOf(payload)
+		if err := handler.Handle(payload); err != nil {
+			metrics.EventsFailed.WithLabelValues(entry.Event, action).Inc()
+			klog.Errorf("Error handling %s delivery %s: %s", entry.Event, entry.ID, err)
+		} else {
+			metrics.EventsHandled.WithLabelValues(entry.Event, action).Inc()
+			s.deliveries.Add(entry.ID)
+		}
+
+		// Failed deliveries would most likely fail again, only the ones whose handling is interrupted are replayed
+		if err := s.journal.Done(entry.ID); err != nil {
+			klog.Errorf("Error marking delivery %s as done: %s", entry.ID, err)
+		}
+	})
+}
+
+// replay enqueues the deliveries whose handling was interrupted by the last shutdown
+func (s *server) replay(entries []*journal.Entry) {
+	for _, entry := range entries {
+		klog.Infof("Replaying %s delivery %s (attempt %d)", entry.Event, entry.ID, entry.Attempts)
+		payload, err := s.parse(http.MethodPost, entry)
+		if err == nil {
+			err = s.enqueue(entry, payload)
+		}
+		if err != nil {
+			klog.Errorf("Error replaying %s delivery %s: %s", entry.Event, entry.ID, err)
+		}
+	}
+}
+
+// schedule runs a task for each repository every interval, queued along with the repository's events so they
+// don't interfere
+func (s *server) schedule(name string, interval time.Duration, repos []string, task func(repo string) error) {
+	if len(repos) == 0 {
+		return
+	}
+
+	every(interval, func() {
+		for _, repo := range repos {
+			repo := repo
+			err := s.queue.Enqueue(repo, func() {
+				if err := task(repo); err != nil {
+					klog.Errorf("Error running %s on %s: %s", name, repo, err)
+				}
+			})
+			if err != nil {
+				klog.Errorf("Error queuing %s on %s: %s", name, repo, err)
+			}
+		}
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.WriteHeader(status)
+	_, err = w.Write([]byte("An error happened: " + err.Error()))
+	if err != nil {
+		klog.Errorf("Failed to write response: %s", err)
+	}
+}
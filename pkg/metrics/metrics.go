@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// durationBuckets are the upper bounds, in seconds, used for the durations of git operations
+var durationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+var (
+	EventsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "submariner_bot_events_received_total",
+		Help: "Webhook events accepted for handling.",
+	}, []string{"event", "action"})
+	EventsHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "submariner_bot_events_handled_total",
+		Help: "Webhook events handled successfully.",
+	}, []string{"event", "action"})
+	EventsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "submariner_bot_events_failed_total",
+		Help: "Webhook events whose handling failed.",
+	}, []string{"event", "action"})
+
+	GitOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "submariner_bot_git_operation_duration_seconds",
+		Help:    "Duration of the git clones, fetches and pushes.",
+		Buckets: durationBuckets,
+	}, []string{"operation"})
+
+	// The GitHub API metrics are labeled with the HTTP method and the route template, such as
+	// /repos/{owner}/{repo}/pulls/{pull_number}, which identifies the API call
+	GitHubAPICalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "submariner_bot_github_api_calls_total",
+		Help: "Requests sent to the GitHub API, including retries.",
+	}, []string{"method", "route"})
+	GitHubAPIErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "submariner_bot_github_api_errors_total",
+		Help: "Requests to the GitHub API which failed or got an error status.",
+	}, []string{"method", "route"})
+	GitHubAPIRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "submariner_bot_github_api_retries_total",
+		Help: "Requests to the GitHub API retried after a server error or a secondary rate limit.",
+	}, []string{"method", "route"})
+
+	// The rate limit metrics are labeled with the quota, which is "token" for the personal token, or
+	// installation-<id> for each installation of the GitHub App
+	GitHubRateLimitLimit = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "submariner_bot_github_rate_limit_limit",
+		Help: "Requests allowed per hour by the GitHub API rate limit.",
+	}, []string{"quota"})
+	GitHubRateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "submariner_bot_github_rate_limit_remaining",
+		Help: "Requests remaining in the current GitHub API rate limit window.",
+	}, []string{"quota"})
+	GitHubRateLimitReset = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "submariner_bot_github_rate_limit_reset_timestamp_seconds",
+		Help: "When the current GitHub API rate limit window is reset.",
+	}, []string{"quota"})
+)
+
+// RegisterQueueDepth exposes the number of events and tasks waiting in the queue, as returned by depth
+func RegisterQueueDepth(depth func() float64) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "submariner_bot_queue_depth",
+		Help: "Events and tasks waiting in the queue.",
+	}, depth)
+}
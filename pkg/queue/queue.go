@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"errors"
+	"sync"
+
+	"k8s.io/klog"
+)
+
+var ErrQueueFull = errors.New("work queue is full")
+
+// Job is a unit of work, jobs sharing the same key are run in the order they were enqueued
+type Job func()
+
+// Queue is a bounded worker pool which runs jobs for different keys concurrently,
+// and jobs for the same key sequentially
+type Queue struct {
+	lock      sync.Mutex
+	pending   map[string][]Job
+	scheduled map[string]bool
+	ready     chan string
+	size      int
+	capacity  int
+}
+
+func New(workers, capacity int) *Queue {
+	q := &Queue{
+		pending:   make(map[string][]Job),
+		scheduled: make(map[string]bool),
+		// every key is at most once in the ready channel, and there can't be more keys than jobs
+		ready:    make(chan string, capacity),
+		capacity: capacity,
+	}
+
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+
+	klog.Infof("Work queue started with %d workers and capacity for %d jobs", workers, capacity)
+	return q
+}
+
+func (q *Queue) Enqueue(key string, job Job) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.size >= q.capacity {
+		return ErrQueueFull
+	}
+
+	q.pending[key] = append(q.pending[key], job)
+	q.size++
+
+	if !q.scheduled[key] {
+		q.scheduled[key] = true
+		q.ready <- key
+	}
+	return nil
+}
+
+// Len returns the number of jobs waiting or being run
+func (q *Queue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.size
+}
+
+func (q *Queue) worker() {
+	for key := range q.ready {
+		q.lock.Lock()
+		job := q.pending[key][0]
+		q.lock.Unlock()
+
+		run(key, job)
+
+		q.lock.Lock()
+		q.pending[key] = q.pending[key][1:]
+		q.size--
+		if len(q.pending[key]) > 0 {
+			q.ready <- key
+		} else {
+			delete(q.pending, key)
+			delete(q.scheduled, key)
+		}
+		q.lock.Unlock()
+	}
+}
+
+func run(key string, job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			klog.Errorf("Job for %s panicked: %v", key, r)
+		}
+	}()
+	job()
+}
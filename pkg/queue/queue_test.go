@@ -0,0 +1,154 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const waitTimeout = 10 * time.Second
+
+func TestJobsForTheSameKeyRunInOrder(t *testing.T) {
+	q := New(4, 100)
+
+	var lock sync.Mutex
+	order := []int{}
+	var running, overlaps int32
+	var done sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		i := i
+		done.Add(1)
+		err := q.Enqueue("owner/repo", func() {
+			defer done.Done()
+			if atomic.AddInt32(&running, 1) > 1 {
+				atomic.AddInt32(&overlaps, 1)
+			}
+			time.Sleep(time.Millisecond)
+
+			lock.Lock()
+			order = append(order, i)
+			lock.Unlock()
+			atomic.AddInt32(&running, -1)
+		})
+		if err != nil {
+			t.Fatalf("Enqueue failed: %s", err)
+		}
+	}
+
+	waitFor(t, &done)
+
+	if overlaps > 0 {
+		t.Errorf("Jobs for the same key ran concurrently %d times", overlaps)
+	}
+	for i, job := range order {
+		if job != i {
+			t.Fatalf("Jobs ran in the order %v, expected the order they were enqueued in", order)
+		}
+	}
+}
+
+func TestJobsForDifferentKeysRunInParallel(t *testing.T) {
+	const workers = 3
+	q := New(workers, 100)
+
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	var done sync.WaitGroup
+
+	for i := 0; i < workers+2; i++ {
+		key := fmt.Sprintf("owner/repo-%d", i)
+		done.Add(1)
+		err := q.Enqueue(key, func() {
+			defer done.Done()
+			started <- key
+			<-release
+		})
+		if err != nil {
+			t.Fatalf("Enqueue failed: %s", err)
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		select {
+		case <-started:
+		case <-time.After(waitTimeout):
+			close(release)
+			t.Fatalf("Only %d jobs for different keys started, expected %d", i, workers)
+		}
+	}
+
+	select {
+	case key := <-started:
+		close(release)
+		t.Fatalf("The job for %s started while all the %d workers were busy", key, workers)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	waitFor(t, &done)
+}
+
+func TestEnqueueRejectsJobsOverCapacity(t *testing.T) {
+	q := New(1, 2)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var done sync.WaitGroup
+
+	done.Add(2)
+	if err := q.Enqueue("owner/repo", func() {
+		defer done.Done()
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatalf("Enqueue failed: %s", err)
+	}
+	if err := q.Enqueue("owner/other", done.Done); err != nil {
+		t.Fatalf("Enqueue failed: %s", err)
+	}
+
+	// The running job still counts against the capacity
+	<-started
+	if err := q.Enqueue("owner/repo", func() {}); !errors.Is(err, ErrQueueFull) {
+		close(release)
+		t.Fatalf("Enqueue over the capacity returned %v, expected %v", err, ErrQueueFull)
+	}
+	if q.Len() != 2 {
+		close(release)
+		t.Fatalf("Len returned %d, expected 2", q.Len())
+	}
+
+	close(release)
+	waitFor(t, &done)
+
+	// A job is only removed from the queue once it returns
+	for deadline := time.Now().Add(waitTimeout); q.Len() != 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Len returned %d once all the jobs ran, expected 0", q.Len())
+		}
+	}
+
+	done.Add(1)
+	if err := q.Enqueue("owner/repo", done.Done); err != nil {
+		t.Fatalf("Enqueue failed once all the jobs ran: %s", err)
+	}
+	waitFor(t, &done)
+}
+
+func waitFor(t *testing.T, done *sync.WaitGroup) {
+	finished := make(chan struct{})
+	go func() {
+		done.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(waitTimeout):
+		t.Fatal("The jobs didn't all run")
+	}
+}